@@ -0,0 +1,139 @@
+package blockingqueue
+
+import (
+    "context"
+    "math/rand/v2"
+    "time"
+)
+
+// TakeAny blocks until any of queues has an element or ctx is done, then
+// removes and returns the head of that queue together with its index in
+// queues. When several queues have elements, one is picked at random, as a
+// select statement does, so none is starved; use TakeAnyPriority to prefer
+// some queues over others.
+//
+// TakeAny starts no goroutines and does not spin: the caller is parked as a
+// consumer on every queue at once and woken, like any other consumer, by the
+// first one that receives an element. It does not take part in fairness (see
+// WithFairness). Closed queues are skipped once drained, and ErrClosed is
+// returned when all of them are, or when queues is empty; on cancellation it
+// returns ctx.Err(). The element counts as taken for TaskDone.
+func TakeAny[T comparable](ctx context.Context, queues ...*Queue[T]) (T, int, error) {
+    return takeAny(ctx, queues, rand.IntN(max(len(queues), 1)))
+}
+
+// TakeAnyPriority is TakeAny that, when several queues have elements, takes
+// from the one that comes first in queues; list queues from high to low
+// priority. A busy high-priority queue can starve the others.
+func TakeAnyPriority[T comparable](ctx context.Context, queues ...*Queue[T]) (T, int, error) {
+    return takeAny(ctx, queues, 0)
+}
+
+// takeAny implements TakeAny, trying queues in order from queues[start] on,
+// wrapping around.
+func takeAny[T comparable](ctx context.Context, queues []*Queue[T], start int) (T, int, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    var zero T
+    ready := make(chan struct{}, 1)
+    nodes := make([]waiter[T], len(queues))
+    woken := make([]bool, len(queues)) // queues whose wake-up the caller consumed
+    for {
+        drained := true
+        for k := range queues {
+            i := (start + k) % len(queues)
+            b := queues[i]
+            b.mu.Lock()
+            b.reclaim()
+            v, ok := b.q.Dequeue()
+            if ok {
+                b.taken++
+                b.putters.wake(1)
+            }
+            drained = drained && b.drained()
+            b.mu.Unlock()
+            if ok {
+                woken[i] = false
+                passOn(queues, woken)
+                return v, i, nil
+            }
+        }
+        passOn(queues, woken)
+        if drained {
+            return zero, -1, ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return zero, -1, err
+        }
+
+        // Park on every queue that is not drained, re-checking each under
+        // its lock so that an element added after the scan above is not
+        // missed. Lease and retry alarms are folded into a single timer.
+        var timer Timer
+        var alarm <-chan time.Time
+        var next time.Duration
+        signaled, parked := false, false
+        for i, b := range queues {
+            b.mu.Lock()
+            if b.q.Len() > 0 {
+                signaled = true
+            } else if b.drained() {
+                b.mu.Unlock()
+                continue
+            }
+            if d, ok := b.alarm(); ok && (timer == nil || d < next) {
+                if timer != nil {
+                    timer.Stop()
+                }
+                timer, next = b.clock.NewTimer(d), d
+                alarm = timer.C()
+            }
+            nodes[i] = waiter[T]{ready: ready, shared: true}
+            b.takers.push(&nodes[i])
+            parked = true
+            b.mu.Unlock()
+        }
+        if !signaled && parked {
+            select {
+            case <-ready:
+            case <-ctx.Done():
+            case <-alarm:
+            }
+        }
+        if timer != nil {
+            timer.Stop()
+        }
+        for i, b := range queues {
+            b.mu.Lock()
+            if nodes[i].list == &b.takers {
+                b.takers.remove(&nodes[i])
+            } else if nodes[i].ready != nil {
+                woken[i] = true // popped by a waker
+            }
+            nodes[i] = waiter[T]{}
+            b.mu.Unlock()
+        }
+        select {
+        case <-ready:
+        default:
+        }
+    }
+}
+
+// passOn hands the wake-ups that takeAny consumed from queues it did not
+// take from to another consumer of each, so that their elements are not left
+// waiting, and clears woken.
+func passOn[T comparable](queues []*Queue[T], woken []bool) {
+    for i, b := range queues {
+        if !woken[i] {
+            continue
+        }
+        woken[i] = false
+        b.mu.Lock()
+        if b.q.Len() > 0 {
+            b.takers.wake(1)
+        }
+        b.mu.Unlock()
+    }
+}
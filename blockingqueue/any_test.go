@@ -0,0 +1,200 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestTakeAnyWaitsOnAllQueues(t *testing.T) {
+    qs := []*Queue[string]{New[string](false), New[string](false)}
+    type result struct {
+        v   string
+        i   int
+        err error
+    }
+    res := make(chan result, 1)
+    go func() {
+        v, i, err := TakeAny(context.Background(), qs...)
+        res <- result{v, i, err}
+    }()
+    waitForTakers(t, qs[0], 1)
+    waitForTakers(t, qs[1], 1)
+    qs[1].Put("x")
+    select {
+    case r := <-res:
+        if r.v != "x" || r.i != 1 || r.err != nil {
+            t.Fatalf("TakeAny = %+v want x from queue 1", r)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("TakeAny not woken by the second queue")
+    }
+    for i, q := range qs {
+        q.mu.Lock()
+        n := q.takers.n
+        q.mu.Unlock()
+        if n != 0 {
+            t.Fatalf("queue %d still has %d parked waiters", i, n)
+        }
+    }
+    if qs[1].Unfinished() != 1 {
+        t.Fatal("a value taken by TakeAny must count as taken")
+    }
+}
+
+func TestTakeAnyPriority(t *testing.T) {
+    hi, lo := New[int](false), New[int](false)
+    seen := map[int]int{}
+    for range 100 {
+        hi.Put(1)
+        lo.Put(2)
+        _, i, _ := TakeAny(context.Background(), hi, lo)
+        seen[i]++
+        hi.Put(1)
+        lo.Put(2)
+        if _, i, _ := TakeAnyPriority(context.Background(), hi, lo); i != 0 {
+            t.Fatal("TakeAnyPriority must prefer the first ready queue")
+        }
+        hi.Clear()
+        lo.Clear()
+    }
+    if len(seen) != 2 {
+        t.Fatalf("TakeAny picked %v: it must not always favor one queue", seen)
+    }
+}
+
+func TestTakeAnyCloseAndCancel(t *testing.T) {
+    a, b := New[int](false), New[int](false)
+    a.Close()
+    b.Put(7)
+    b.Close()
+    if v, i, err := TakeAny(context.Background(), a, b); v != 7 || i != 1 || err != nil {
+        t.Fatalf("TakeAny = %d,%d,%v want 7 from the open queue", v, i, err)
+    }
+    if _, _, err := TakeAny(context.Background(), a, b); !errors.Is(err, ErrClosed) {
+        t.Fatalf("TakeAny on drained queues = %v want ErrClosed", err)
+    }
+    if _, _, err := TakeAny[int](context.Background()); !errors.Is(err, ErrClosed) {
+        t.Fatalf("TakeAny with no queues = %v want ErrClosed", err)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if _, i, err := TakeAny(ctx, New[int](false)); !errors.Is(err, context.DeadlineExceeded) || i != -1 {
+        t.Fatalf("TakeAny = %d,%v want -1,DeadlineExceeded", i, err)
+    }
+}
+
+func TestTakeAnyWakesOnLeaseExpiry(t *testing.T) {
+    clk := newFakeClock()
+    a := New[int](false, WithClock(clk), WithVisibilityTimeout(time.Minute))
+    a.Put(1)
+    a.TryReserve()
+    res := make(chan int, 1)
+    go func() {
+        v, _, _ := TakeAny(context.Background(), New[int](false), a)
+        res <- v
+    }()
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Minute)
+    select {
+    case v := <-res:
+        if v != 1 {
+            t.Fatalf("TakeAny = %d want 1", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("TakeAny not woken when the lease expired")
+    }
+}
+
+func TestTakeAnyIgnoresDrainedQueue(t *testing.T) {
+    closed, open := New[int](false), New[int](false)
+    closed.Close()
+    res := make(chan int, 1)
+    go func() {
+        v, _, _ := TakeAny(context.Background(), closed, open)
+        res <- v
+    }()
+    waitForTakers(t, open, 1)
+    // A waiter woken over and over would keep leaving the list; it must stay
+    // parked on the open queue only.
+    for deadline := time.Now().Add(20 * time.Millisecond); time.Now().Before(deadline); {
+        closed.mu.Lock()
+        onClosed := closed.takers.n
+        closed.mu.Unlock()
+        open.mu.Lock()
+        onOpen := open.takers.n
+        open.mu.Unlock()
+        if onClosed != 0 || onOpen != 1 {
+            t.Fatalf("parked %d on the closed and %d on the open queue, want 0 and 1", onClosed, onOpen)
+        }
+    }
+    open.Put(5)
+    select {
+    case v := <-res:
+        if v != 5 {
+            t.Fatalf("TakeAny = %d want 5", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("TakeAny not woken by the open queue")
+    }
+}
+
+func TestTakeAnyPassesOnWakeUps(t *testing.T) {
+    a, b := New[int](false), New[int](false)
+    got := make(chan int)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    consume := func(take func() (int, error)) {
+        for {
+            v, err := take()
+            if err != nil {
+                return
+            }
+            got <- v
+        }
+    }
+    for range 2 {
+        go consume(func() (int, error) { v, _, err := TakeAny(ctx, a, b); return v, err })
+        go consume(func() (int, error) { return a.Take(ctx) })
+        go consume(func() (int, error) { return b.Take(ctx) })
+    }
+    // Every value must be taken promptly, even when a TakeAny caller woken by
+    // one queue takes from the other.
+    for i := range 500 {
+        q := a
+        if i%3 == 0 {
+            q = b
+        }
+        q.Put(i)
+        select {
+        case <-got:
+        case <-time.After(time.Second):
+            t.Fatalf("value %d was not taken: a wake-up was lost", i)
+        }
+    }
+}
+
+func TestWakePassesOverSignaledSharedWaiter(t *testing.T) {
+    var l waitList[int]
+    shared := &waiter[int]{ready: make(chan struct{}, 1), shared: true}
+    shared.ready <- struct{}{} // already woken by another queue
+    plain := &waiter[int]{ready: make(chan struct{}, 1)}
+    l.push(shared)
+    l.push(plain)
+    l.wake(1)
+    select {
+    case <-plain.ready:
+    default:
+        t.Fatal("the wake-up must go to the next waiter")
+    }
+
+    l.push(shared)
+    l.push(plain)
+    if !l.handoff(5) || !plain.ok || plain.v != 5 {
+        t.Fatal("handoff must pass over shared waiters")
+    }
+    if l.n != 1 || l.head != shared {
+        t.Fatal("a passed-over shared waiter must stay parked")
+    }
+}
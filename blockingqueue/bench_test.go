@@ -2,8 +2,11 @@ package blockingqueue
 
 import (
     "context"
+    "sync"
     "testing"
     "time"
+
+    base "github.com/xyhelper/xyqueue"
 )
 
 // Benchmark pairs of Put/Take with a single consumer.
@@ -43,3 +46,117 @@ func BenchmarkTryTake(b *testing.B) {
     }
 }
 
+
+// condQueue reproduces the previous waiting strategy for comparison: Take
+// spawns a watcher goroutine per wait and Put broadcasts to every waiter.
+type condQueue[T comparable] struct {
+    mu sync.Mutex
+    cv *sync.Cond
+    q  *base.Queue[T]
+}
+
+func newCondQueue[T comparable]() *condQueue[T] {
+    c := &condQueue[T]{q: base.New[T](false)}
+    c.cv = sync.NewCond(&c.mu)
+    return c
+}
+
+func (c *condQueue[T]) Put(v T) {
+    c.mu.Lock()
+    c.q.Enqueue(v)
+    c.cv.Broadcast()
+    c.mu.Unlock()
+}
+
+func (c *condQueue[T]) Take(ctx context.Context) (T, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for {
+        if v, ok := c.q.Dequeue(); ok {
+            return v, nil
+        }
+        if err := ctx.Err(); err != nil {
+            var zero T
+            return zero, err
+        }
+        done := make(chan struct{})
+        go func() {
+            select {
+            case <-ctx.Done():
+                c.mu.Lock()
+                c.cv.Broadcast()
+                c.mu.Unlock()
+            case <-done:
+            }
+        }()
+        c.cv.Wait()
+        close(done)
+    }
+}
+
+type putTaker interface {
+    put(int)
+    take(context.Context) (int, error)
+}
+
+type condAdapter struct{ q *condQueue[int] }
+
+func (a condAdapter) put(v int)                             { a.q.Put(v) }
+func (a condAdapter) take(ctx context.Context) (int, error) { return a.q.Take(ctx) }
+
+type waitListAdapter struct{ q *Queue[int] }
+
+func (a waitListAdapter) put(v int)                             { a.q.Put(v) }
+func (a waitListAdapter) take(ctx context.Context) (int, error) { return a.q.Take(ctx) }
+
+// benchIdleConsumers hands b.N items, one at a time, to a pool of idle
+// consumers blocked in Take. Each item is put only after the previous one was
+// taken, so every Put has to wake a parked consumer: this is where broadcast
+// wake-ups and per-wait watcher goroutines cost the most.
+func benchIdleConsumers(b *testing.B, q putTaker, consumers int) {
+    ctx, cancel := context.WithCancel(context.Background())
+    var wg sync.WaitGroup
+    taken := make(chan struct{})
+    for i := 0; i < consumers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                if _, err := q.take(ctx); err != nil {
+                    return
+                }
+                taken <- struct{}{}
+            }
+        }()
+    }
+    time.Sleep(10 * time.Millisecond) // let consumers park
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        q.put(i)
+        <-taken
+    }
+    b.StopTimer()
+    cancel()
+    wg.Wait()
+}
+
+func BenchmarkIdleConsumers1000_Cond(b *testing.B) {
+    benchIdleConsumers(b, condAdapter{newCondQueue[int]()}, 1000)
+}
+
+func BenchmarkIdleConsumers1000_WaitList(b *testing.B) {
+    benchIdleConsumers(b, waitListAdapter{New[int](false)}, 1000)
+}
+
+func BenchmarkPutTake_Cond(b *testing.B) {
+    benchIdleConsumers(b, condAdapter{newCondQueue[int]()}, 1)
+}
+
+func BenchmarkPutTake_WaitList(b *testing.B) {
+    benchIdleConsumers(b, waitListAdapter{New[int](false)}, 1)
+}
+
+func BenchmarkIdleConsumers1000_Fair(b *testing.B) {
+    benchIdleConsumers(b, waitListAdapter{New[int](false, WithFairness())}, 1000)
+}
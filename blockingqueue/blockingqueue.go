@@ -4,121 +4,395 @@ import (
     "context"
     "errors"
     "sync"
+    "time"
 
     base "github.com/xyhelper/xyqueue"
 )
 
 // Queue is a blocking, concurrency-safe FIFO built on xyqueue with optional
 // de-duplication. When de-duplication is enabled, Put skips values already
-// present; after removal the value can be added again.
+// present; after removal the value can be added again. When the queue is
+// bounded (see WithMaxSize), producers block while it is full.
+//
+// With WithFairness, blocked consumers are served strictly in the order they
+// called Take.
+//
+// Close stops producers; consumers keep draining the remaining elements and
+// then get ErrClosed.
 //
 // All methods are safe for concurrent use by multiple goroutines.
 type Queue[T comparable] struct {
-    mu sync.Mutex
-    cv *sync.Cond
-    q  *base.Queue[T]
+    mu       sync.Mutex
+    takers   waitList[T] // consumers waiting for an element
+    putters  waitList[T] // producers waiting for space
+    matchers waitList[T] // TakeIf callers waiting for a matching element
+    joiners  waitList[T] // Join callers waiting for every value to be finished
+    q        *base.Queue[T]
+    fair     bool
+    dedup    bool
+
+    clock      Clock
+    visibility time.Duration
+    leases     leaseHeap[T]
+    retries    retryHeap[T]
+    backoff    Backoff
+    outside    map[T]struct{} // values leased or awaiting a retry; only used when dedup is true
+    attempts   map[T]int      // failed deliveries of values not yet acknowledged
+    taken      int            // values taken and not yet reported done
+
+    maxDeliveries int
+    dead          *base.Queue[DeadLetter[T]] // nil without WithDeadLetter
+
+    closed bool
 }
 
-// New creates a new blocking queue.
-func New[T comparable](dedup bool) *Queue[T] {
-    b := &Queue[T]{q: base.New[T](dedup)}
-    b.cv = sync.NewCond(&b.mu)
-    return b
+// New creates a new blocking queue. opts may bound the queue (WithMaxSize) or
+// configure the underlying xyqueue.Queue (WithQueueOptions).
+func New[T comparable](dedup bool, opts ...Option) *Queue[T] {
+    o := collect(opts)
+    return newQueue(base.New[T](dedup, o.queue...), dedup, o)
 }
 
 // NewWithCapacity creates a new blocking queue with initial capacity.
-func NewWithCapacity[T comparable](dedup bool, capacity int) *Queue[T] {
-    b := &Queue[T]{q: base.NewWithCapacity[T](dedup, capacity)}
-    b.cv = sync.NewCond(&b.mu)
+func NewWithCapacity[T comparable](dedup bool, capacity int, opts ...Option) *Queue[T] {
+    o := collect(opts)
+    return newQueue(base.NewWithCapacity[T](dedup, capacity, o.queue...), dedup, o)
+}
+
+func newQueue[T comparable](q *base.Queue[T], dedup bool, o options) *Queue[T] {
+    b := &Queue[T]{
+        q: q, fair: o.fair, dedup: dedup,
+        clock: o.clock, visibility: o.visibility, backoff: o.backoff,
+        attempts: make(map[T]int),
+    }
+    if o.maxDeliveries > 0 {
+        b.maxDeliveries = o.maxDeliveries
+        b.dead = base.New[DeadLetter[T]](false, o.deadLetter...)
+    }
+    if dedup {
+        b.outside = make(map[T]struct{})
+    }
     return b
 }
 
 // Put appends v to the tail. Returns true if the value was added, or false
-// when de-duplication is enabled and v is already present. Wakes waiters only
-// when an element is actually added.
+// when de-duplication is enabled and v is already present. Wakes one waiting
+// consumer only when an element is actually added. Returns false once the
+// queue is closed.
+//
+// If the queue is bounded and its overflow policy rejects v, Put blocks until
+// space becomes available; use PutContext to bound the wait or TryPut to not
+// wait at all. With an evicting policy (DropOldest, DropNewest) Put never
+// blocks.
 func (b *Queue[T]) Put(v T) bool {
+    added, _ := b.PutContext(context.Background(), v)
+    return added
+}
+
+// PutContext appends v to the tail, waiting while the queue is full until
+// space becomes available or ctx is done. Returns (true, nil) when v was
+// added, (false, nil) when de-duplication skipped, coalesced or moved it,
+// (false, ctx.Err()) on cancellation and (false, ErrClosed) if the queue is
+// or becomes closed.
+func (b *Queue[T]) PutContext(ctx context.Context, v T) (bool, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
     b.mu.Lock()
-    added := b.q.Enqueue(v)
-    if added {
-        b.cv.Broadcast()
+    defer b.mu.Unlock()
+    for {
+        if b.closed {
+            return false, ErrClosed
+        }
+        switch b.offer(v) {
+        case base.Added:
+            return true, nil
+        case base.Duplicate, base.Replaced, base.Moved:
+            return false, nil
+        }
+        if err := ctx.Err(); err != nil {
+            return false, err
+        }
+        b.wait(ctx, &b.putters)
     }
-    b.mu.Unlock()
-    return added
 }
 
-// PutMany enqueues items and returns the count actually added.
-// Broadcasts once if any element is added.
-func (b *Queue[T]) PutMany(items ...T) int {
+// TryPut appends v to the tail without blocking. Returns (true, nil) when v
+// was added, (false, nil) when de-duplication skipped it, (false, ErrFull)
+// when the queue is full and (false, ErrClosed) when it is closed.
+func (b *Queue[T]) TryPut(v T) (bool, error) {
     b.mu.Lock()
-    n := b.q.EnqueueMany(items...)
-    if n > 0 {
-        b.cv.Broadcast()
+    defer b.mu.Unlock()
+    if b.closed {
+        return false, ErrClosed
     }
-    b.mu.Unlock()
+    switch b.offer(v) {
+    case base.Added:
+        return true, nil
+    case base.Full:
+        return false, ErrFull
+    }
+    return false, nil
+}
+
+// Requeue returns v, typically a value just taken, to the head of the queue
+// so that it is taken next, and wakes one waiting consumer. In fair mode v
+// goes straight to the longest-waiting consumer, if any. Requeue never
+// blocks; results are as for TryPut, so on ErrFull (a bounded queue filled up
+// in the meantime) or ErrClosed the caller still owns v.
+//
+// Requeue does not finish the take that returned v: call TaskDone for it
+// afterwards, as after Retry, whatever the result. Once requeued, v counts
+// as queued until it is taken and finished again (see Join).
+func (b *Queue[T]) Requeue(v T) (bool, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.closed {
+        return false, ErrClosed
+    }
+    if b.isOutside(v) {
+        return false, nil
+    }
+    switch b.restore(v) {
+    case base.Added:
+        return true, nil
+    case base.Full:
+        return false, ErrFull
+    }
+    return false, nil
+}
+
+// restore puts v back at the head of the queue and wakes waiters as offer
+// does; in fair mode v goes straight to the longest-waiting consumer, if any.
+// b.mu must be held.
+func (b *Queue[T]) restore(v T) base.Result {
+    if b.fair && b.takers.handoff(v) {
+        return base.Added
+    }
+    r := b.q.OfferFront(v)
+    if r == base.Added {
+        b.takers.wake(1)
+        b.matchers.wakeAll()
+    }
+    return r
+}
+
+// OfferTimeout appends v to the tail, waiting up to timeout for space when the
+// queue is full. Results are as for TryPut: ErrFull means no space became
+// available in time.
+func (b *Queue[T]) OfferTimeout(v T, timeout time.Duration) (bool, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    added, err := b.PutContext(ctx, v)
+    if errors.Is(err, context.DeadlineExceeded) {
+        err = ErrFull
+    }
+    return added, err
+}
+
+// PutMany enqueues items and returns the count actually added, waking one
+// waiting consumer per added element. On a bounded queue it blocks until
+// every item has fit; see PutManyContext.
+func (b *Queue[T]) PutMany(items ...T) int {
+    n, _ := b.PutManyContext(context.Background(), items...)
     return n
 }
 
+// PutManyContext enqueues items in order, waiting for space whenever the
+// queue is full, and returns the count actually added. Items are added
+// atomically when they all fit; otherwise the items added so far are already
+// visible to consumers while the call waits. On cancellation or close it
+// returns the count added before that together with ctx.Err() or ErrClosed.
+func (b *Queue[T]) PutManyContext(ctx context.Context, items ...T) (int, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    added := 0
+    for _, v := range items {
+        for {
+            if b.closed {
+                return added, ErrClosed
+            }
+            r := b.offer(v)
+            if r == base.Added {
+                added++
+                break
+            }
+            if r != base.Full {
+                break
+            }
+            if err := ctx.Err(); err != nil {
+                return added, err
+            }
+            b.wait(ctx, &b.putters)
+        }
+    }
+    return added, nil
+}
+
+// offer adds v to the queue, waking one waiting consumer and every TakeIf
+// caller so they can check v. In fair mode v is instead handed straight to
+// the longest-waiting consumer, if any, so a newcomer cannot take it first.
+// A value that is leased or awaiting a retry counts as a duplicate. b.mu must
+// be held.
+func (b *Queue[T]) offer(v T) base.Result {
+    if b.isOutside(v) {
+        return base.Duplicate
+    }
+    if b.fair && b.takers.handoff(v) {
+        return base.Added
+    }
+    r := b.q.Offer(v)
+    if r == base.Added {
+        b.takers.wake(1)
+        b.matchers.wakeAll()
+    }
+    return r
+}
+
+// isOutside reports whether de-duplication is enabled and v is leased or
+// awaiting a retry, so that it counts as present. b.mu must be held.
+func (b *Queue[T]) isOutside(v T) bool {
+    _, ok := b.outside[v]
+    return ok
+}
+
+// setOutside records whether v is leased or awaiting a retry. b.mu must be
+// held.
+func (b *Queue[T]) setOutside(v T, out bool) {
+    switch {
+    case b.outside == nil:
+    case out:
+        b.outside[v] = struct{}{}
+    default:
+        delete(b.outside, v)
+    }
+}
+
+// drained reports whether the queue is closed and no value can come back to
+// it, so consumers finding it empty report ErrClosed. b.mu must be held.
+func (b *Queue[T]) drained() bool {
+    return b.closed && len(b.leases) == 0 && len(b.retries) == 0
+}
+
 // TryTake removes and returns the head value without blocking.
 // ok is false if the queue is empty.
 func (b *Queue[T]) TryTake() (v T, ok bool) {
     b.mu.Lock()
+    b.reclaim()
     v, ok = b.q.Dequeue()
+    if ok {
+        b.taken++
+        b.putters.wake(1)
+    }
     b.mu.Unlock()
     return
 }
 
 // Take blocks until an element is available or ctx is done. On success returns
-// (value, nil). On cancellation returns the zero value and ctx.Err().
+// (value, nil). On cancellation returns the zero value and ctx.Err(). After
+// Close, Take keeps returning the remaining elements and then ErrClosed; while
+// leases are outstanding (see Reserve) or retries pending (see Retry) it
+// waits, since their values may yet return to the queue.
 func (b *Queue[T]) Take(ctx context.Context) (T, error) {
     if ctx == nil {
         ctx = context.Background()
     }
     b.mu.Lock()
-    // Fast path
-    if v, ok := b.q.Dequeue(); ok {
-        b.mu.Unlock()
-        return v, nil
-    }
-    // Wait with context cancellation. We spawn a short-lived watcher that
-    // broadcasts on cancellation to wake Wait.
+    defer b.mu.Unlock()
     for {
-        done := make(chan struct{})
-        go func() {
-            select {
-            case <-ctx.Done():
-                b.mu.Lock()
-                b.cv.Broadcast()
-                b.mu.Unlock()
-            case <-done:
-            }
-        }()
-
-        b.cv.Wait() // releases and re-acquires b.mu
-        close(done)
-
+        b.reclaim()
         if v, ok := b.q.Dequeue(); ok {
-            b.mu.Unlock()
+            b.taken++
+            b.putters.wake(1)
             return v, nil
         }
+        if b.drained() {
+            var zero T
+            return zero, ErrClosed
+        }
         if err := ctx.Err(); err != nil {
-            b.mu.Unlock()
             var zero T
             return zero, err
         }
+        if v, ok := b.wait(ctx, &b.takers); ok {
+            b.taken++
+            return v, nil
+        }
+    }
+}
+
+// TakeBatch blocks like Take until at least one element is available, then
+// returns up to max elements (max <= 0 is treated as 1). When fewer than max
+// are queued it keeps collecting newly added elements for up to linger, so
+// producers get a chance to fill the batch; linger <= 0 returns immediately
+// with whatever was queued.
+//
+// Errors are those of Take and are only returned when no element was taken;
+// once the first element is in hand, cancellation or Close merely ends the
+// linger early and the partial batch is returned with a nil error.
+func (b *Queue[T]) TakeBatch(ctx context.Context, max int, linger time.Duration) ([]T, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    if max <= 0 {
+        max = 1
+    }
+    first, err := b.Take(ctx)
+    if err != nil {
+        return nil, err
+    }
+    batch := append(make([]T, 0, min(max, 64)), first)
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    batch = b.takeInto(batch, max)
+    if len(batch) == max || linger <= 0 {
+        return batch, nil
+    }
+    lctx, cancel := context.WithTimeout(ctx, linger)
+    defer cancel()
+    for len(batch) < max && !b.closed && lctx.Err() == nil {
+        if v, ok := b.wait(lctx, &b.takers); ok {
+            b.taken++
+            batch = append(batch, v)
+        }
+        batch = b.takeInto(batch, max)
     }
+    return batch, nil
+}
+
+// takeInto moves queued elements into batch until it holds max, waking one
+// blocked producer per element removed. b.mu must be held.
+func (b *Queue[T]) takeInto(batch []T, max int) []T {
+    n := len(batch)
+    if n >= max {
+        // DequeueManyInto reads a limit of 0 as no limit.
+        return batch
+    }
+    b.reclaim()
+    batch = b.q.DequeueManyInto(batch, max-n)
+    b.taken += len(batch) - n
+    b.putters.wake(len(batch) - n)
+    return batch
 }
 
 // Peek returns the head value without removing it. ok is false when empty.
 func (b *Queue[T]) Peek() (v T, ok bool) {
     b.mu.Lock()
+    b.reclaim()
     v, ok = b.q.Peek()
     b.mu.Unlock()
     return
 }
 
-// Len returns the number of elements currently queued.
+// Len returns the number of elements currently queued, not counting leased
+// ones (see InFlight).
 func (b *Queue[T]) Len() int {
     b.mu.Lock()
+    b.reclaim()
     n := b.q.Len()
     b.mu.Unlock()
     return n
@@ -127,9 +401,29 @@ func (b *Queue[T]) Len() int {
 // IsEmpty reports whether the queue is empty.
 func (b *Queue[T]) IsEmpty() bool { return b.Len() == 0 }
 
-// Contains reports whether v is currently present in the queue.
+// MaxSize returns the maximum number of elements the queue holds, or 0 when
+// it is unbounded.
+func (b *Queue[T]) MaxSize() int { return b.q.MaxSize() }
+
+// SetOverflowFunc installs fn as the overflow callback of the underlying
+// xyqueue.Queue (see xyqueue.Queue.SetOverflowFunc). A value it rejects
+// makes Put block as when the queue is full.
+func (b *Queue[T]) SetOverflowFunc(fn func(v T) base.OverflowPolicy) { b.q.SetOverflowFunc(fn) }
+
+// SetExpiryFunc installs fn as the expiry callback of the underlying
+// xyqueue.Queue (see xyqueue.Queue.SetExpiryFunc).
+func (b *Queue[T]) SetExpiryFunc(fn func(v T)) { b.q.SetExpiryFunc(fn) }
+
+// SetMergeFunc selects the Coalesce duplicate policy of the underlying
+// xyqueue.Queue with fn as its merge function (see
+// xyqueue.Queue.SetMergeFunc). It only matters with de-duplication.
+func (b *Queue[T]) SetMergeFunc(fn func(old, new T) T) { b.q.SetMergeFunc(fn) }
+
+// Contains reports whether v is currently present in the queue. A leased
+// value is not.
 func (b *Queue[T]) Contains(v T) bool {
     b.mu.Lock()
+    b.reclaim()
     ok := b.q.Contains(v)
     b.mu.Unlock()
     return ok
@@ -140,6 +434,10 @@ func (b *Queue[T]) Contains(v T) bool {
 func (b *Queue[T]) Remove(v T) bool {
     b.mu.Lock()
     removed := b.q.Remove(v)
+    if removed {
+        b.putters.wake(1)
+        b.checkJoin()
+    }
     b.mu.Unlock()
     return removed
 }
@@ -148,17 +446,77 @@ func (b *Queue[T]) Remove(v T) bool {
 func (b *Queue[T]) Clear() {
     b.mu.Lock()
     b.q.Clear()
+    clear(b.attempts)
+    b.putters.wakeAll()
+    b.checkJoin()
     b.mu.Unlock()
 }
 
+// Close marks the queue closed. Subsequent puts fail with ErrClosed (Put
+// returns false) and producers blocked on a full queue are released with
+// ErrClosed. Elements already queued remain available: Take keeps returning
+// them and reports ErrClosed once the queue is empty and no lease or retry is
+// outstanding. Leases can still be settled. Close is idempotent.
+func (b *Queue[T]) Close() {
+    b.mu.Lock()
+    b.closed = true
+    b.takers.wakeAll()
+    b.putters.wakeAll()
+    b.matchers.wakeAll()
+    b.mu.Unlock()
+}
+
+// CloseNow closes the queue like Close and discards every queued element,
+// outstanding lease and pending retry, so blocked and future Take calls
+// return ErrClosed immediately.
+func (b *Queue[T]) CloseNow() {
+    b.mu.Lock()
+    b.closed = true
+    b.q.Clear()
+    for _, l := range b.leases {
+        l.i = -1
+    }
+    clear(b.leases)
+    b.leases = b.leases[:0]
+    clear(b.retries)
+    b.retries = b.retries[:0]
+    clear(b.outside)
+    clear(b.attempts)
+    b.takers.wakeAll()
+    b.putters.wakeAll()
+    b.matchers.wakeAll()
+    b.checkJoin()
+    b.mu.Unlock()
+}
+
+// IsClosed reports whether Close or CloseNow has been called.
+func (b *Queue[T]) IsClosed() bool {
+    b.mu.Lock()
+    closed := b.closed
+    b.mu.Unlock()
+    return closed
+}
+
 // ErrCanceled is returned by Take when the context is canceled.
 var ErrCanceled = context.Canceled
 
 // ErrDeadlineExceeded is returned by Take when the context deadline expires.
 var ErrDeadlineExceeded = context.DeadlineExceeded
 
+// ErrFull is returned by TryPut and OfferTimeout when a bounded queue has no
+// room for the value.
+var ErrFull = errors.New("blockingqueue: queue is full")
+
+// ErrClosed is returned by puts on a closed queue and by Take once a closed
+// queue has been drained.
+var ErrClosed = errors.New("blockingqueue: queue is closed")
+
 // IsContextError reports whether err equals context.Canceled or context.DeadlineExceeded.
 func IsContextError(err error) bool {
     return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
+// IsClosedError reports whether err is ErrClosed.
+func IsClosedError(err error) bool {
+    return errors.Is(err, ErrClosed)
+}
@@ -6,6 +6,8 @@ import (
     "sync"
     "testing"
     "time"
+
+    base "github.com/xyhelper/xyqueue"
 )
 
 func TestTakeBlocksAndWakes(t *testing.T) {
@@ -93,3 +95,568 @@ func TestHighConcurrency(t *testing.T) {
     wg.Wait()
 }
 
+
+func TestBoundedQueueOptions(t *testing.T) {
+    bq := New[int](false, WithQueueOptions(base.WithMaxSize(2, base.DropOldest)))
+    if n := bq.PutMany(1, 2, 3); n != 3 {
+        t.Fatalf("putmany=%d want 3", n)
+    }
+    if v, _ := bq.Peek(); v != 2 {
+        t.Fatalf("head=%d want 2 after eviction", v)
+    }
+    rq := New[int](false, WithQueueOptions(base.WithMaxSize(1, base.Reject)))
+    if !rq.Put(1) {
+        t.Fatal("expected first put to add element")
+    }
+    if ok, err := rq.TryPut(2); ok || err != ErrFull {
+        t.Fatalf("tryput got (%v,%v) want (false,ErrFull)", ok, err)
+    }
+}
+
+func TestPutDuplicateOnFullDoesNotBlock(t *testing.T) {
+    bq := New[int](true, WithMaxSize(1), WithQueueOptions(base.WithDuplicatePolicy(base.Coalesce)))
+    bq.Put(1)
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    if ok, err := bq.PutContext(ctx, 1); ok || err != nil {
+        t.Fatalf("put of queued value on full queue got (%v,%v) want (false,nil)", ok, err)
+    }
+    if n, err := bq.PutManyContext(ctx, 1, 1); n != 0 || err != nil {
+        t.Fatalf("putmany got (%d,%v) want (0,nil)", n, err)
+    }
+
+    mq := New[int](true, WithMaxSize(2), WithQueueOptions(base.WithDuplicatePolicy(base.MoveToBack)))
+    mq.PutMany(1, 2)
+    if ok, err := mq.PutContext(ctx, 1); ok || err != nil {
+        t.Fatalf("put of queued value on full queue got (%v,%v) want (false,nil)", ok, err)
+    }
+    if v, _ := mq.Peek(); v != 2 {
+        t.Fatalf("head=%d want 2 after moving 1 to the back", v)
+    }
+}
+
+func TestPutBlocksWhenFull(t *testing.T) {
+    bq := New[int](false, WithMaxSize(2))
+    bq.PutMany(1, 2)
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        if !bq.Put(3) {
+            t.Error("expected blocked put to eventually add")
+        }
+    }()
+    select {
+    case <-done:
+        t.Fatal("put should block while the queue is full")
+    case <-time.After(20 * time.Millisecond):
+    }
+    if v, _ := bq.TryTake(); v != 1 {
+        t.Fatalf("take=%d want 1", v)
+    }
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("put did not wake after space was freed")
+    }
+    if got := bq.Len(); got != 2 {
+        t.Fatalf("len=%d want 2", got)
+    }
+}
+
+func TestSetOverflowFunc(t *testing.T) {
+    bq := New[int](false, WithMaxSize(1))
+    bq.SetOverflowFunc(func(v int) base.OverflowPolicy {
+        if v%2 == 0 {
+            return base.DropOldest
+        }
+        return base.Reject
+    })
+    bq.Put(1)
+    if ok, err := bq.TryPut(2); !ok || err != nil {
+        t.Fatalf("tryput(2) got (%v,%v) want (true,nil)", ok, err)
+    }
+    if ok, err := bq.TryPut(3); ok || err != ErrFull {
+        t.Fatalf("tryput(3) got (%v,%v) want (false,ErrFull)", ok, err)
+    }
+    if v, _ := bq.Peek(); v != 2 {
+        t.Fatalf("head=%d want 2", v)
+    }
+}
+
+func TestPutWakesOnExpiry(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithMaxSize(1), WithQueueOptions(base.WithTTL(time.Minute)))
+    bq.Put(1)
+    done := make(chan int, 1)
+    go func() { done <- bq.PutMany(2) }()
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Minute)
+    select {
+    case n := <-done:
+        if n != 1 {
+            t.Fatalf("putmany=%d want 1", n)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("blocked PutMany not woken when the value expired")
+    }
+
+    added := make(chan bool, 1)
+    go func() { added <- bq.Put(3) }()
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Minute)
+    select {
+    case ok := <-added:
+        if !ok {
+            t.Fatal("blocked Put did not add after the value expired")
+        }
+    case <-time.After(time.Second):
+        t.Fatal("blocked Put not woken when the value expired")
+    }
+}
+
+func TestPutContextCancel(t *testing.T) {
+    bq := New[int](true, WithMaxSize(1))
+    bq.Put(1)
+    if ok, err := bq.PutContext(context.Background(), 1); ok || err != nil {
+        t.Fatalf("duplicate put got (%v,%v) want (false,nil)", ok, err)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    ok, err := bq.PutContext(ctx, 2)
+    if ok || !IsContextError(err) {
+        t.Fatalf("putcontext got (%v,%v) want context error", ok, err)
+    }
+    if ok, err := bq.OfferTimeout(2, 10*time.Millisecond); ok || err != ErrFull {
+        t.Fatalf("offertimeout got (%v,%v) want (false,ErrFull)", ok, err)
+    }
+}
+
+func TestPutManyContextPartial(t *testing.T) {
+    bq := New[int](false, WithMaxSize(2))
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    n, err := bq.PutManyContext(ctx, 1, 2, 3, 4)
+    if n != 2 || !IsContextError(err) {
+        t.Fatalf("putmanycontext got (%d,%v) want (2, context error)", n, err)
+    }
+
+    // With a consumer draining, every item eventually fits.
+    bq = New[int](false, WithMaxSize(2))
+    got := make(chan int, 10)
+    go func() {
+        for i := 0; i < 10; i++ {
+            v, err := bq.Take(context.Background())
+            if err != nil {
+                return
+            }
+            got <- v
+        }
+    }()
+    if n := bq.PutMany(0, 1, 2, 3, 4, 5, 6, 7, 8, 9); n != 10 {
+        t.Fatalf("putmany=%d want 10", n)
+    }
+    for i := 0; i < 10; i++ {
+        if v := <-got; v != i {
+            t.Fatalf("take=%d want %d", v, i)
+        }
+    }
+}
+
+func TestCloseDrainsThenErrClosed(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 2)
+    bq.Close()
+    bq.Close() // idempotent
+    if !bq.IsClosed() {
+        t.Fatal("expected IsClosed after Close")
+    }
+    if bq.Put(3) {
+        t.Fatal("expected put after close to fail")
+    }
+    if _, err := bq.TryPut(3); !IsClosedError(err) {
+        t.Fatalf("tryput err=%v want ErrClosed", err)
+    }
+    ctx := context.Background()
+    for want := 1; want <= 2; want++ {
+        v, err := bq.Take(ctx)
+        if err != nil || v != want {
+            t.Fatalf("take got (%d,%v) want (%d,nil)", v, err, want)
+        }
+    }
+    if _, err := bq.Take(ctx); err != ErrClosed {
+        t.Fatalf("take err=%v want ErrClosed", err)
+    }
+    if IsContextError(ErrClosed) || IsClosedError(context.Canceled) {
+        t.Fatal("error helpers must not overlap")
+    }
+}
+
+func TestCloseWakesBlockedCallers(t *testing.T) {
+    bq := New[int](false, WithMaxSize(1))
+    bq.Put(0)
+    errs := make(chan error, 2)
+    go func() {
+        _, err := bq.PutContext(context.Background(), 1)
+        errs <- err
+    }()
+    empty := New[int](false)
+    go func() {
+        _, err := empty.Take(context.Background())
+        errs <- err
+    }()
+    time.Sleep(10 * time.Millisecond)
+    bq.Close()
+    empty.Close()
+    for i := 0; i < 2; i++ {
+        select {
+        case err := <-errs:
+            if !IsClosedError(err) {
+                t.Fatalf("err=%v want ErrClosed", err)
+            }
+        case <-time.After(time.Second):
+            t.Fatal("blocked caller not released by Close")
+        }
+    }
+    if bq.Len() != 1 {
+        t.Fatalf("len=%d want 1 (Close keeps queued items)", bq.Len())
+    }
+}
+
+func TestCloseNowDiscards(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 2, 3)
+    bq.CloseNow()
+    if bq.Len() != 0 {
+        t.Fatalf("len=%d want 0 after CloseNow", bq.Len())
+    }
+    if _, err := bq.Take(context.Background()); !IsClosedError(err) {
+        t.Fatalf("take err=%v want ErrClosed", err)
+    }
+}
+
+func TestCanceledWaitersUnlink(t *testing.T) {
+    bq := New[int](false, WithMaxSize(1))
+    bq.Put(0)
+    var wg sync.WaitGroup
+    for i := 0; i < 50; i++ {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+            defer cancel()
+            New[int](false).Take(ctx)
+        }()
+        go func() {
+            defer wg.Done()
+            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+            defer cancel()
+            bq.PutContext(ctx, 1)
+        }()
+    }
+    wg.Wait()
+    bq.mu.Lock()
+    n := bq.putters.n + bq.takers.n
+    bq.mu.Unlock()
+    if n != 0 {
+        t.Fatalf("%d waiters left linked after cancellation", n)
+    }
+}
+
+func TestNoLostItemsWithCancellingConsumers(t *testing.T) {
+    bq := New[int](false)
+    const total = 2000
+    var consumed sync.Map
+    var count sync.WaitGroup
+    count.Add(total)
+    stop := make(chan struct{})
+    var wg sync.WaitGroup
+    for i := 0; i < 16; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                select {
+                case <-stop:
+                    return
+                default:
+                }
+                // Short timeouts make consumers give up while others are
+                // being woken for new items.
+                ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+                v, err := bq.Take(ctx)
+                cancel()
+                if err == nil {
+                    if _, dup := consumed.LoadOrStore(v, true); dup {
+                        t.Errorf("value %d taken twice", v)
+                    }
+                    count.Done()
+                }
+            }
+        }()
+    }
+    for i := 0; i < total; i++ {
+        bq.Put(i)
+        if i%100 == 0 {
+            time.Sleep(time.Millisecond)
+        }
+    }
+    done := make(chan struct{})
+    go func() { count.Wait(); close(done) }()
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatalf("items stranded: %d left in queue", bq.Len())
+    }
+    close(stop)
+    wg.Wait()
+}
+
+// waitForTakers spins until n consumers are parked in Take.
+func waitForTakers[T comparable](t *testing.T, bq *Queue[T], n int) {
+    t.Helper()
+    deadline := time.Now().Add(time.Second)
+    for {
+        bq.mu.Lock()
+        parked := bq.takers.n
+        bq.mu.Unlock()
+        if parked >= n {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("only %d of %d consumers parked", parked, n)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestFairnessArrivalOrder(t *testing.T) {
+    bq := New[int](false, WithFairness())
+    const n = 8
+    got := make([]chan int, n)
+    for i := 0; i < n; i++ {
+        got[i] = make(chan int, 1)
+        go func(i int) {
+            v, err := bq.Take(context.Background())
+            if err != nil {
+                t.Errorf("take: %v", err)
+            }
+            got[i] <- v
+        }(i)
+        waitForTakers(t, bq, i+1) // park consumers one by one, in order
+    }
+    for i := 0; i < n; i++ {
+        bq.Put(i * 10)
+        // A greedy newcomer must not overtake the parked consumers.
+        if v, ok := bq.TryTake(); ok {
+            t.Fatalf("TryTake stole %d from a parked consumer", v)
+        }
+    }
+    for i := 0; i < n; i++ {
+        if v := <-got[i]; v != i*10 {
+            t.Fatalf("consumer %d got %d want %d", i, v, i*10)
+        }
+    }
+}
+
+func TestFairnessNoStarvation(t *testing.T) {
+    bq := New[int](false, WithFairness())
+    const consumers, perConsumer = 4, 50
+    counts := make([]int, consumers)
+    var wg sync.WaitGroup
+    for c := 0; c < consumers; c++ {
+        wg.Add(1)
+        go func(c int) {
+            defer wg.Done()
+            for {
+                _, err := bq.Take(context.Background())
+                if err != nil {
+                    return
+                }
+                counts[c]++
+            }
+        }(c)
+    }
+    // Hand items out one at a time while every consumer is parked, so each
+    // delivery goes to whoever has waited longest: a strict rotation.
+    for i := 0; i < consumers*perConsumer; i++ {
+        waitForTakers(t, bq, consumers)
+        bq.Put(i)
+    }
+    waitForTakers(t, bq, consumers)
+    bq.Close()
+    wg.Wait()
+    for c, n := range counts {
+        if n != perConsumer {
+            t.Fatalf("consumer %d took %d items want %d (counts %v)", c, n, perConsumer, counts)
+        }
+    }
+}
+
+func TestFairnessCancelKeepsHandedValue(t *testing.T) {
+    bq := New[int](false, WithFairness())
+    ctx, cancel := context.WithCancel(context.Background())
+    res := make(chan int, 1)
+    go func() {
+        v, err := bq.Take(ctx)
+        if err != nil {
+            res <- -1
+            return
+        }
+        res <- v
+    }()
+    waitForTakers(t, bq, 1)
+    bq.mu.Lock()
+    bq.offer(7) // hand off, then cancel before the consumer runs
+    cancel()
+    bq.mu.Unlock()
+    if v := <-res; v != 7 {
+        t.Fatalf("take got %d want the handed value 7", v)
+    }
+    if bq.Len() != 0 {
+        t.Fatal("handed value must not remain queued")
+    }
+}
+
+func TestTakeBatch(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 2, 3, 4, 5)
+    batch, err := bq.TakeBatch(context.Background(), 3, 0)
+    if err != nil || len(batch) != 3 || batch[0] != 1 || batch[2] != 3 {
+        t.Fatalf("takebatch got (%v,%v) want [1 2 3]", batch, err)
+    }
+    // Fewer than max queued and no linger: return what is there.
+    batch, _ = bq.TakeBatch(context.Background(), 10, 0)
+    if len(batch) != 2 {
+        t.Fatalf("takebatch got %v want [4 5]", batch)
+    }
+
+    // Linger collects items that arrive shortly after the first one.
+    go func() {
+        bq.Put(6)
+        time.Sleep(5 * time.Millisecond)
+        bq.PutMany(7, 8)
+    }()
+    batch, err = bq.TakeBatch(context.Background(), 3, time.Second)
+    if err != nil || len(batch) != 3 || batch[0] != 6 || batch[2] != 8 {
+        t.Fatalf("takebatch got (%v,%v) want [6 7 8]", batch, err)
+    }
+
+    // Linger expiry returns a partial batch without error.
+    bq.Put(9)
+    start := time.Now()
+    batch, err = bq.TakeBatch(context.Background(), 3, 20*time.Millisecond)
+    if err != nil || len(batch) != 1 || time.Since(start) < 20*time.Millisecond {
+        t.Fatalf("takebatch got (%v,%v) after %v", batch, err, time.Since(start))
+    }
+}
+
+func TestTakeBatchMax(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 2, 3, 4, 5)
+    for _, max := range []int{1, 0} {
+        if batch, _ := bq.TakeBatch(context.Background(), max, 0); len(batch) != 1 {
+            t.Fatalf("takebatch max=%d got %v want 1 element", max, batch)
+        }
+    }
+
+    // A value handed over during the linger may fill the batch; the rest
+    // must stay queued.
+    fq := New[int](false, WithFairness())
+    fq.Put(1)
+    go func() {
+        waitForTakers(t, fq, 1)
+        fq.PutMany(2, 3, 4, 5, 6)
+    }()
+    batch, err := fq.TakeBatch(context.Background(), 2, time.Second)
+    if err != nil || len(batch) != 2 {
+        t.Fatalf("fair takebatch got (%v,%v) want 2 elements", batch, err)
+    }
+    if n := fq.Len(); n != 4 {
+        t.Fatalf("len=%d after fair takebatch want 4", n)
+    }
+}
+
+func TestTakeBatchErrors(t *testing.T) {
+    bq := New[int](false)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if batch, err := bq.TakeBatch(ctx, 4, time.Second); batch != nil || !IsContextError(err) {
+        t.Fatalf("takebatch got (%v,%v) want context error", batch, err)
+    }
+    bq.Put(1)
+    go func() {
+        time.Sleep(5 * time.Millisecond)
+        bq.Close()
+    }()
+    // Close ends the linger early; the partial batch is still returned.
+    batch, err := bq.TakeBatch(context.Background(), 4, time.Second)
+    if err != nil || len(batch) != 1 {
+        t.Fatalf("takebatch got (%v,%v) want ([1],nil)", batch, err)
+    }
+    if _, err := bq.TakeBatch(context.Background(), 4, 0); !IsClosedError(err) {
+        t.Fatalf("takebatch err=%v want ErrClosed", err)
+    }
+}
+
+func TestTakeBatchFreesSpace(t *testing.T) {
+    bq := New[int](false, WithMaxSize(2))
+    bq.PutMany(1, 2)
+    done := make(chan int)
+    go func() { done <- bq.PutMany(3, 4) }()
+    time.Sleep(5 * time.Millisecond)
+    if batch, _ := bq.TakeBatch(context.Background(), 2, 0); len(batch) != 2 {
+        t.Fatalf("takebatch got %v", batch)
+    }
+    select {
+    case n := <-done:
+        if n != 2 {
+            t.Fatalf("putmany=%d want 2", n)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("blocked producers not woken by TakeBatch")
+    }
+}
+
+func TestRequeue(t *testing.T) {
+    bq := New[int](false, WithMaxSize(2))
+    bq.PutMany(1, 2)
+    v, _ := bq.Take(context.Background())
+    if ok, err := bq.Requeue(v); !ok || err != nil {
+        t.Fatalf("requeue got (%v,%v) want (true,nil)", ok, err)
+    }
+    if head, _ := bq.Peek(); head != 1 {
+        t.Fatalf("head=%d want requeued 1", head)
+    }
+    if ok, err := bq.Requeue(3); ok || err != ErrFull {
+        t.Fatalf("requeue on full queue got (%v,%v) want (false,ErrFull)", ok, err)
+    }
+    bq.Close()
+    if ok, err := bq.Requeue(3); ok || err != ErrClosed {
+        t.Fatalf("requeue on closed queue got (%v,%v) want (false,ErrClosed)", ok, err)
+    }
+}
+
+func TestRequeueWakesTaker(t *testing.T) {
+    for _, fair := range []bool{false, true} {
+        var opts []Option
+        if fair {
+            opts = append(opts, WithFairness())
+        }
+        bq := New[int](false, opts...)
+        got := make(chan int, 1)
+        go func() {
+            v, _ := bq.Take(context.Background())
+            got <- v
+        }()
+        waitForTakers(t, bq, 1)
+        bq.Requeue(7)
+        select {
+        case v := <-got:
+            if v != 7 {
+                t.Fatalf("fair=%v: took %d want 7", fair, v)
+            }
+        case <-time.After(time.Second):
+            t.Fatalf("fair=%v: waiting consumer not woken by Requeue", fair)
+        }
+    }
+}
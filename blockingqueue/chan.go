@@ -0,0 +1,70 @@
+package blockingqueue
+
+import (
+    "context"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// Chan returns a channel that delivers the queue's values, so they can be
+// received in a select statement or passed to channel-based code. A single
+// goroutine takes values with Take and sends them on the unbuffered channel
+// one at a time, so the queue itself is the buffer. The channel is closed
+// once the queue is closed and drained, or ctx is done; a value taken but not
+// yet received when ctx ends goes back to the head of the queue.
+//
+// Values received count as taken for TaskDone and Join. Receive until the
+// channel is closed or cancel ctx, or the goroutine leaks.
+func (b *Queue[T]) Chan(ctx context.Context) <-chan T {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    out := make(chan T)
+    go func() {
+        defer close(out)
+        for {
+            v, err := b.Take(ctx)
+            if err != nil {
+                return
+            }
+            select {
+            case out <- v:
+            case <-ctx.Done():
+                b.giveBack(v)
+                return
+            }
+        }
+    }()
+    return out
+}
+
+// FromChan creates a queue fed from ch: a goroutine puts every value received
+// on ch, so with de-duplication a value already queued is dropped, and on a
+// bounded queue (WithMaxSize) the goroutine stops receiving while the queue
+// is full, pushing back on the senders. The queue is closed once ch is
+// closed, so consumers drain it and then get ErrClosed. Closing the queue
+// first stops the feeding; values still sent on ch are then left unreceived.
+func FromChan[T comparable](ch <-chan T, dedup bool, opts ...Option) *Queue[T] {
+    b := New[T](dedup, opts...)
+    go func() {
+        defer b.Close()
+        for v := range ch {
+            if _, err := b.PutContext(context.Background(), v); err != nil {
+                return
+            }
+        }
+    }()
+    return b
+}
+
+// giveBack returns v, taken but not delivered, to the head of the queue. If
+// a bounded queue has filled up in the meantime it waits for room, which
+// consumers make even after Close.
+func (b *Queue[T]) giveBack(v T) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.taken--
+    for b.restore(v) == base.Full {
+        park(context.Background(), &b.mu, &b.putters)
+    }
+}
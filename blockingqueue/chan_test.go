@@ -0,0 +1,75 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestChanDeliversAndCloses(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 2)
+    ch := bq.Chan(context.Background())
+    for want := 1; want <= 2; want++ {
+        if v := <-ch; v != want {
+            t.Fatalf("received %d want %d", v, want)
+        }
+    }
+    bq.Close()
+    select {
+    case _, ok := <-ch:
+        if ok {
+            t.Fatal("no value was queued")
+        }
+    case <-time.After(time.Second):
+        t.Fatal("channel not closed with the queue")
+    }
+}
+
+func TestChanCancelGivesBack(t *testing.T) {
+    bq := New[int](false)
+    bq.Put(1)
+    ctx, cancel := context.WithCancel(context.Background())
+    ch := bq.Chan(ctx)
+    deadline := time.Now().Add(time.Second)
+    for bq.Len() != 0 { // wait until the pump holds 1
+        if time.Now().After(deadline) {
+            t.Fatal("pump did not take the value")
+        }
+        time.Sleep(time.Millisecond)
+    }
+    cancel()
+    for range ch {
+        // The pump may win the race and deliver 1 before seeing ctx end.
+        bq.Put(1)
+    }
+    if v, ok := bq.Peek(); !ok || v != 1 || bq.Unfinished() != 1 {
+        t.Fatalf("Peek = %d,%v unfinished %d: an undelivered value must go back", v, ok, bq.Unfinished())
+    }
+}
+
+func TestFromChan(t *testing.T) {
+    ch := make(chan string)
+    bq := FromChan(ch, true)
+    for _, v := range []string{"a", "a", "b"} {
+        ch <- v
+    }
+    close(ch)
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    var got []string
+    for {
+        v, err := bq.Take(ctx)
+        if errors.Is(err, ErrClosed) {
+            break
+        }
+        if err != nil {
+            t.Fatalf("take: %v", err)
+        }
+        got = append(got, v)
+    }
+    if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+        t.Fatalf("received %v want [a b]", got)
+    }
+}
@@ -0,0 +1,38 @@
+package blockingqueue
+
+import "time"
+
+// Clock is the source of time for queues that schedule deliveries, such as
+// DelayQueue. The default is the system clock; tests can inject a fake that
+// is advanced manually (see WithClock) so they never have to sleep.
+type Clock interface {
+    // Now returns the current time.
+    Now() time.Time
+    // NewTimer returns a timer that delivers on its channel once d has
+    // elapsed.
+    NewTimer(d time.Duration) Timer
+}
+
+// Timer is a one-shot timer created by a Clock.
+type Timer interface {
+    // C returns the channel on which the time is delivered.
+    C() <-chan time.Time
+    // Stop prevents the timer from firing and reports whether it was still
+    // pending.
+    Stop() bool
+}
+
+// SystemClock is the Clock backed by package time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+
+func (t systemTimer) Stop() bool { return t.t.Stop() }
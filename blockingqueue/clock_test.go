@@ -0,0 +1,92 @@
+package blockingqueue
+
+import (
+    "sync"
+    "testing"
+    "time"
+)
+
+// fakeClock is a manually advanced Clock for tests.
+type fakeClock struct {
+    mu     sync.Mutex
+    now    time.Time
+    timers []*fakeTimer
+}
+
+type fakeTimer struct {
+    c        chan time.Time
+    deadline time.Time
+    clock    *fakeClock
+}
+
+func newFakeClock() *fakeClock {
+    return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    t := &fakeTimer{c: make(chan time.Time, 1), deadline: c.now.Add(d), clock: c}
+    if d <= 0 {
+        t.c <- c.now
+        return t
+    }
+    c.timers = append(c.timers, t)
+    return t
+}
+
+// Advance moves the clock forward and fires every timer that fell due.
+func (c *fakeClock) Advance(d time.Duration) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.now = c.now.Add(d)
+    kept := c.timers[:0]
+    for _, t := range c.timers {
+        if !t.deadline.After(c.now) {
+            t.c <- c.now
+            continue
+        }
+        kept = append(kept, t)
+    }
+    c.timers = kept
+}
+
+// Pending returns the number of timers that have not fired or been stopped.
+func (c *fakeClock) Pending() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return len(c.timers)
+}
+
+// waitForTimers spins until at least n timers are pending on c.
+func waitForTimers(t *testing.T, c *fakeClock, n int) {
+    t.Helper()
+    deadline := time.Now().Add(time.Second)
+    for c.Pending() < n {
+        if time.Now().After(deadline) {
+            t.Fatalf("only %d of %d timers armed", c.Pending(), n)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+    c := t.clock
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for i, x := range c.timers {
+        if x == t {
+            c.timers = append(c.timers[:i], c.timers[i+1:]...)
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,89 @@
+package blockingqueue
+
+import (
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// DeadLetter is a value that was given up on after too many failed
+// deliveries (see WithDeadLetter).
+type DeadLetter[T comparable] struct {
+    Value    T
+    Err      error     // error of the last failed delivery; nil for Nack
+    Attempts int       // deliveries made
+    At       time.Time // when the value was dead-lettered
+}
+
+// DeadLetters returns the dead-letter queue, or nil unless the queue was
+// created with WithDeadLetter. It is an ordinary xyqueue.Queue, safe for
+// concurrent use, holding dead letters oldest first: inspect it with ToSlice,
+// All or Peek, and purge it with Dequeue, RemoveFunc or Clear. Use Replay to
+// give values another chance.
+func (b *Queue[T]) DeadLetters() *base.Queue[DeadLetter[T]] { return b.dead }
+
+// Replay moves the dead letters for which pred returns true back to the tail
+// of the queue, with their delivery count reset, and returns how many it
+// moved. A dead letter whose value is already present is dropped as if
+// replayed when de-duplication is enabled. Replay never blocks: dead letters
+// that do not fit in a full bounded queue stay put and Replay reports
+// ErrFull; on a closed queue it replays nothing and returns ErrClosed.
+//
+// pred is called with the queue's lock held and must not call methods on the
+// queue.
+func (b *Queue[T]) Replay(pred func(d DeadLetter[T]) bool) (int, error) {
+    if b.dead == nil {
+        return 0, nil
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.closed {
+        return 0, ErrClosed
+    }
+    full := false
+    n := b.dead.RemoveFunc(func(d DeadLetter[T]) bool {
+        if !pred(d) {
+            return false
+        }
+        if b.offer(d.Value) == base.Full {
+            full = true
+            return false
+        }
+        return true
+    })
+    if full {
+        return n, ErrFull
+    }
+    return n, nil
+}
+
+// fail settles l as a failed delivery: its value returns to the head of the
+// queue or, once it has been delivered maxDeliveries times, moves to the
+// dead-letter queue with err. It reports false, leaving l held, when a full
+// bounded queue has no room for the value. b.mu must be held.
+func (b *Queue[T]) fail(l *Lease[T], err error) bool {
+    if b.exhausted(l.attempt) {
+        b.bury(l.v, l.attempt, err)
+    } else {
+        if b.restore(l.v) == base.Full {
+            return false
+        }
+        b.attempts[l.v] = l.attempt
+    }
+    b.release(l)
+    b.checkJoin()
+    return true
+}
+
+// exhausted reports whether a value that failed its n-th delivery must be
+// dead-lettered. b.mu must be held.
+func (b *Queue[T]) exhausted(n int) bool {
+    return b.dead != nil && n >= b.maxDeliveries
+}
+
+// bury moves v to the dead-letter queue after its n-th delivery failed with
+// err. b.mu must be held.
+func (b *Queue[T]) bury(v T, n int, err error) {
+    b.dead.Enqueue(DeadLetter[T]{Value: v, Err: err, Attempts: n, At: b.clock.Now()})
+    delete(b.attempts, v)
+}
@@ -0,0 +1,101 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+func TestDeadLetterAfterMaxDeliveries(t *testing.T) {
+    bq := New[string](true, WithDeadLetter(2))
+    bq.PutMany("poison", "ok")
+    errA, errB := errors.New("a"), errors.New("b")
+    l, _ := bq.Reserve(context.Background())
+    if l.Attempt() != 1 {
+        t.Fatalf("first Attempt = %d want 1", l.Attempt())
+    }
+    if err := l.Fail(errA); err != nil {
+        t.Fatalf("Fail: %v", err)
+    }
+    l, _ = bq.Reserve(context.Background())
+    if l.Value() != "poison" || l.Attempt() != 2 {
+        t.Fatalf("redelivery = %q attempt %d want poison attempt 2", l.Value(), l.Attempt())
+    }
+    if err := l.Fail(errB); err != nil {
+        t.Fatalf("Fail: %v", err)
+    }
+    if v, _ := bq.Peek(); v != "ok" || bq.Len() != 1 {
+        t.Fatalf("poison must leave the queue after its last delivery, head %q", v)
+    }
+    dl := bq.DeadLetters().ToSlice()
+    if len(dl) != 1 || dl[0].Value != "poison" || dl[0].Err != errB || dl[0].Attempts != 2 {
+        t.Fatalf("dead letters = %+v", dl)
+    }
+
+    n, err := bq.Replay(func(d DeadLetter[string]) bool { return d.Value == "poison" })
+    if n != 1 || err != nil || bq.DeadLetters().Len() != 0 {
+        t.Fatalf("Replay = %d,%v", n, err)
+    }
+    bq.TryTake() // ok
+    l, _ = bq.TryReserve()
+    if l.Value() != "poison" || l.Attempt() != 1 {
+        t.Fatalf("replayed value = %q attempt %d want a fresh delivery count", l.Value(), l.Attempt())
+    }
+}
+
+func TestAckResetsAttempts(t *testing.T) {
+    bq := New[int](false, WithDeadLetter(2))
+    bq.Put(1)
+    l, _ := bq.TryReserve()
+    l.Nack()
+    l, _ = bq.TryReserve()
+    if err := l.Ack(); err != nil || l.Attempt() != 2 {
+        t.Fatalf("Ack = %v attempt %d", err, l.Attempt())
+    }
+    bq.Put(1)
+    if l, _ = bq.TryReserve(); l.Attempt() != 1 {
+        t.Fatalf("Attempt after Ack = %d want 1", l.Attempt())
+    }
+}
+
+func TestDeadLetterOnExpiry(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithVisibilityTimeout(time.Second), WithDeadLetter(1))
+    bq.Put(7)
+    l, _ := bq.TryReserve()
+    clk.Advance(time.Second)
+    if bq.InFlight() != 0 || bq.Len() != 0 {
+        t.Fatal("an expired last delivery must not return to the queue")
+    }
+    d, ok := bq.DeadLetters().Peek()
+    if !ok || d.Value != 7 || !errors.Is(d.Err, ErrLeaseExpired) || !d.At.Equal(clk.Now()) {
+        t.Fatalf("dead letter = %+v,%v", d, ok)
+    }
+    if err := l.Ack(); !errors.Is(err, ErrLeaseExpired) {
+        t.Fatalf("Ack after dead-lettering = %v want ErrLeaseExpired", err)
+    }
+}
+
+func TestReplayFull(t *testing.T) {
+    bq := New[int](false, WithMaxSize(1), WithDeadLetter(1, base.WithMaxSize(10, base.DropOldest)))
+    bq.PutMany(1)
+    l, _ := bq.TryReserve()
+    l.Fail(nil)
+    bq.Put(2)
+    if n, err := bq.Replay(func(DeadLetter[int]) bool { return true }); n != 0 || !errors.Is(err, ErrFull) {
+        t.Fatalf("Replay on full queue = %d,%v want 0,ErrFull", n, err)
+    }
+    if bq.DeadLetters().Len() != 1 {
+        t.Fatal("a dead letter that did not fit must stay")
+    }
+    bq.Close()
+    if _, err := bq.Replay(func(DeadLetter[int]) bool { return true }); !errors.Is(err, ErrClosed) {
+        t.Fatalf("Replay on closed queue = %v want ErrClosed", err)
+    }
+    if New[int](false).DeadLetters() != nil {
+        t.Fatal("DeadLetters must be nil without WithDeadLetter")
+    }
+}
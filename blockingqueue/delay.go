@@ -0,0 +1,196 @@
+package blockingqueue
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// DelayQueue is a blocking queue whose values become visible only once they
+// are due: EnqueueAt schedules a value for a given time and Take waits until
+// the earliest scheduled value is due. Values due at the same time are taken
+// in the order they were enqueued.
+//
+// It is built on xyqueue.PriorityQueue ordered by due time, so with
+// de-duplication enabled a value is ignored while it is present at all,
+// whether still pending or already due. Time comes from the Clock given with
+// WithClock (SystemClock by default).
+//
+// All methods are safe for concurrent use by multiple goroutines.
+type DelayQueue[T comparable] struct {
+    mu     sync.Mutex
+    takers waitList[T]
+    pq     *base.PriorityQueue[T]
+    clock  Clock
+    closed bool
+}
+
+// NewDelayQueue creates a delay queue. Of the options, only WithClock
+// applies.
+func NewDelayQueue[T comparable](dedup bool, opts ...Option) *DelayQueue[T] {
+    o := collect(opts)
+    return &DelayQueue[T]{pq: base.NewPriorityQueue[T](dedup), clock: o.clock}
+}
+
+// EnqueueAt schedules v to become available at t; a time in the past makes
+// it available immediately. Returns false when de-duplication is enabled and
+// v is already present (pending or due), or when the queue is closed.
+func (d *DelayQueue[T]) EnqueueAt(v T, t time.Time) bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if d.closed || !d.pq.EnqueuePriority(v, t.UnixNano()) {
+        return false
+    }
+    // A new head moves the earliest due time forward: wake a consumer so it
+    // re-arms its timer.
+    if head, _ := d.pq.Peek(); head == v {
+        d.takers.wake(1)
+    }
+    return true
+}
+
+// EnqueueAfter schedules v to become available after delay. See EnqueueAt.
+func (d *DelayQueue[T]) EnqueueAfter(v T, delay time.Duration) bool {
+    return d.EnqueueAt(v, d.clock.Now().Add(delay))
+}
+
+// TryTake removes and returns the earliest value if it is due, without
+// blocking. ok is false if no value is due yet.
+func (d *DelayQueue[T]) TryTake() (v T, ok bool) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    v, _, ok = d.takeDue()
+    return v, ok
+}
+
+// Take blocks until the earliest value is due or ctx is done, then removes
+// and returns it. Errors are as for Queue.Take: ctx.Err() on cancellation and
+// ErrClosed once a closed queue is drained. After Close, values still pending
+// are delivered when they fall due.
+func (d *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    for {
+        v, wait, ok := d.takeDue()
+        if ok {
+            // Let another consumer pick up (and time) the next value.
+            if d.pq.Len() > 0 {
+                d.takers.wake(1)
+            }
+            return v, nil
+        }
+        var zero T
+        if d.closed && d.pq.Len() == 0 {
+            return zero, ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            // We may have consumed the wake-up meant to time the head; pass
+            // it on so the next value is not overslept.
+            if d.pq.Len() > 0 {
+                d.takers.wake(1)
+            }
+            return zero, err
+        }
+        var timer Timer
+        var alarm <-chan time.Time
+        if wait > 0 {
+            timer = d.clock.NewTimer(wait)
+            alarm = timer.C()
+        }
+        parkUntil(ctx, &d.mu, &d.takers, alarm)
+        if timer != nil {
+            timer.Stop()
+        }
+    }
+}
+
+// takeDue dequeues the head if it is due. Otherwise it reports how long until
+// the head is due, or 0 when the queue is empty. d.mu must be held.
+func (d *DelayQueue[T]) takeDue() (v T, wait time.Duration, ok bool) {
+    _, due, ok := d.pq.PeekPriority()
+    if !ok {
+        return v, 0, false
+    }
+    if wait = time.Duration(due - d.clock.Now().UnixNano()); wait > 0 {
+        return v, wait, false
+    }
+    v, _ = d.pq.Dequeue()
+    return v, 0, true
+}
+
+// Len returns the number of values in the queue, pending or due.
+func (d *DelayQueue[T]) Len() int {
+    d.mu.Lock()
+    n := d.pq.Len()
+    d.mu.Unlock()
+    return n
+}
+
+// IsEmpty reports whether the queue is empty.
+func (d *DelayQueue[T]) IsEmpty() bool { return d.Len() == 0 }
+
+// Due returns the time at which v becomes available, if it is present.
+func (d *DelayQueue[T]) Due(v T) (time.Time, bool) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    due, ok := d.pq.Priority(v)
+    if !ok {
+        return time.Time{}, false
+    }
+    return time.Unix(0, due), true
+}
+
+// Contains reports whether v is present, pending or due.
+func (d *DelayQueue[T]) Contains(v T) bool {
+    d.mu.Lock()
+    ok := d.pq.Contains(v)
+    d.mu.Unlock()
+    return ok
+}
+
+// Remove cancels v if present. Returns true if removed.
+func (d *DelayQueue[T]) Remove(v T) bool {
+    d.mu.Lock()
+    removed := d.pq.Remove(v)
+    d.mu.Unlock()
+    return removed
+}
+
+// Clear removes all values from the queue.
+func (d *DelayQueue[T]) Clear() {
+    d.mu.Lock()
+    d.pq.Clear()
+    d.mu.Unlock()
+}
+
+// Close marks the queue closed: EnqueueAt and EnqueueAfter fail, while Take
+// keeps delivering the remaining values as they fall due and then returns
+// ErrClosed.
+func (d *DelayQueue[T]) Close() {
+    d.mu.Lock()
+    d.closed = true
+    d.takers.wakeAll()
+    d.mu.Unlock()
+}
+
+// CloseNow closes the queue and discards every value, pending or due.
+func (d *DelayQueue[T]) CloseNow() {
+    d.mu.Lock()
+    d.closed = true
+    d.pq.Clear()
+    d.takers.wakeAll()
+    d.mu.Unlock()
+}
+
+// IsClosed reports whether Close or CloseNow has been called.
+func (d *DelayQueue[T]) IsClosed() bool {
+    d.mu.Lock()
+    closed := d.closed
+    d.mu.Unlock()
+    return closed
+}
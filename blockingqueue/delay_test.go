@@ -0,0 +1,141 @@
+package blockingqueue
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestDelayQueueOrderAndDue(t *testing.T) {
+    clk := newFakeClock()
+    dq := NewDelayQueue[string](false, WithClock(clk))
+    dq.EnqueueAfter("b", 2*time.Second)
+    dq.EnqueueAfter("a", time.Second)
+    dq.EnqueueAt("now", clk.Now().Add(-time.Second))
+    if v, ok := dq.TryTake(); !ok || v != "now" {
+        t.Fatalf("trytake got (%q,%v) want past-due value", v, ok)
+    }
+    if _, ok := dq.TryTake(); ok {
+        t.Fatal("nothing should be due yet")
+    }
+    clk.Advance(time.Second)
+    if v, ok := dq.TryTake(); !ok || v != "a" {
+        t.Fatalf("trytake got (%q,%v) want a", v, ok)
+    }
+    if due, ok := dq.Due("b"); !ok || !due.Equal(clk.Now().Add(time.Second)) {
+        t.Fatalf("due(b)=%v,%v", due, ok)
+    }
+}
+
+func TestDelayQueueTakeWaitsForClock(t *testing.T) {
+    clk := newFakeClock()
+    dq := NewDelayQueue[int](false, WithClock(clk))
+    dq.EnqueueAfter(1, time.Minute)
+    res := make(chan int, 1)
+    go func() {
+        v, err := dq.Take(context.Background())
+        if err != nil {
+            t.Errorf("take: %v", err)
+        }
+        res <- v
+    }()
+    waitForTimers(t, clk, 1)
+    select {
+    case <-res:
+        t.Fatal("take returned before the value was due")
+    case <-time.After(10 * time.Millisecond):
+    }
+    clk.Advance(time.Minute)
+    select {
+    case v := <-res:
+        if v != 1 {
+            t.Fatalf("take=%d want 1", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("take not woken when the value fell due")
+    }
+}
+
+func TestDelayQueueEarlierItemRearms(t *testing.T) {
+    clk := newFakeClock()
+    dq := NewDelayQueue[string](false, WithClock(clk))
+    dq.EnqueueAfter("late", time.Hour)
+    res := make(chan string, 1)
+    go func() {
+        v, _ := dq.Take(context.Background())
+        res <- v
+    }()
+    waitForTimers(t, clk, 1)
+    // A value due sooner than the current head must wake the consumer so
+    // it waits for the new, earlier deadline instead.
+    dq.EnqueueAfter("soon", time.Second)
+    deadline := time.Now().Add(time.Second)
+    for {
+        clk.Advance(time.Second)
+        select {
+        case v := <-res:
+            if v != "soon" {
+                t.Fatalf("take=%q want soon", v)
+            }
+            return
+        case <-time.After(time.Millisecond):
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("consumer overslept the earlier value")
+        }
+    }
+}
+
+func TestDelayQueueDedupAcrossPendingAndReady(t *testing.T) {
+    clk := newFakeClock()
+    dq := NewDelayQueue[string](true, WithClock(clk))
+    if !dq.EnqueueAfter("job", time.Second) {
+        t.Fatal("first enqueue should succeed")
+    }
+    if dq.EnqueueAfter("job", 0) {
+        t.Fatal("pending value must count as present")
+    }
+    clk.Advance(time.Second)
+    if dq.EnqueueAt("job", clk.Now()) {
+        t.Fatal("due value must count as present")
+    }
+    if v, ok := dq.TryTake(); !ok || v != "job" {
+        t.Fatalf("trytake got (%q,%v)", v, ok)
+    }
+    if !dq.EnqueueAfter("job", time.Second) {
+        t.Fatal("value can be scheduled again after it was taken")
+    }
+    if !dq.Remove("job") || dq.Contains("job") {
+        t.Fatal("remove should cancel the pending value")
+    }
+}
+
+func TestDelayQueueCloseAndCancel(t *testing.T) {
+    clk := newFakeClock()
+    dq := NewDelayQueue[int](false, WithClock(clk))
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if _, err := dq.Take(ctx); !IsContextError(err) {
+        t.Fatalf("take err=%v want context error", err)
+    }
+    dq.EnqueueAfter(1, time.Second)
+    dq.Close()
+    if dq.EnqueueAfter(2, 0) {
+        t.Fatal("enqueue after close must fail")
+    }
+    res := make(chan error, 2)
+    go func() {
+        _, err := dq.Take(context.Background())
+        res <- err
+        _, err = dq.Take(context.Background())
+        res <- err
+    }()
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Second)
+    if err := <-res; err != nil {
+        t.Fatalf("pending value should still be delivered after Close: %v", err)
+    }
+    if err := <-res; !IsClosedError(err) {
+        t.Fatalf("take err=%v want ErrClosed", err)
+    }
+}
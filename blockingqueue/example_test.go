@@ -59,3 +59,85 @@ func Example_errorHandling() {
     // 1 true
     // empty false
 }
+
+func Example_backpressure() {
+    bq := New[int](false, WithMaxSize(1))
+    bq.Put(1)
+
+    // The queue is full: TryPut fails fast, OfferTimeout gives up after a wait.
+    _, err := bq.TryPut(2)
+    fmt.Println(err == ErrFull)
+    _, err = bq.OfferTimeout(2, 10*time.Millisecond)
+    fmt.Println(err == ErrFull)
+
+    // A blocked Put resumes once a consumer frees a slot.
+    go func() {
+        time.Sleep(10 * time.Millisecond)
+        bq.TryTake()
+    }()
+    fmt.Println(bq.Put(2))
+    v, _ := bq.TryTake()
+    fmt.Println(v)
+    // Output:
+    // true
+    // true
+    // true
+    // 2
+}
+
+func Example_close() {
+    bq := New[string](false)
+    bq.PutMany("a", "b")
+    bq.Close()
+    fmt.Println(bq.Put("c")) // rejected after Close
+
+    // Consumers drain what was queued, then see ErrClosed.
+    for {
+        v, err := bq.Take(context.Background())
+        if IsClosedError(err) {
+            fmt.Println("closed")
+            break
+        }
+        fmt.Println(v)
+    }
+    // Output:
+    // false
+    // a
+    // b
+    // closed
+}
+
+func Example_stream() {
+    bq := New[int](false)
+    go func() {
+        bq.PutMany(1, 2, 3)
+        bq.Close()
+    }()
+    for v, err := range bq.Stream(context.Background()) {
+        if err != nil {
+            fmt.Println("error:", err)
+            break
+        }
+        fmt.Println(v)
+    }
+    // Output:
+    // 1
+    // 2
+    // 3
+}
+
+func Example_delayQueue() {
+    dq := NewDelayQueue[string](true)
+    dq.EnqueueAfter("later", 20*time.Millisecond)
+    dq.EnqueueAfter("sooner", 10*time.Millisecond)
+    fmt.Println(dq.EnqueueAfter("sooner", 0)) // already scheduled
+
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    v1, _ := dq.Take(ctx)
+    v2, _ := dq.Take(ctx)
+    fmt.Println(v1, v2)
+    // Output:
+    // false
+    // sooner later
+}
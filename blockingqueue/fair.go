@@ -0,0 +1,383 @@
+package blockingqueue
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// FairQueue is a blocking queue shared by many tenants that keeps one noisy
+// tenant from starving the rest. Every tenant key gets its own sub-queue, an
+// xyqueue.Queue with its own de-duplication, and Take serves the tenants
+// with values in turn: by weighted round-robin, where a tenant of weight w
+// gets up to w values per round (see SetWeight), or by deficit round-robin
+// when values differ in cost (see SetCost). Tenants can be
+// capped (see WithTenantCap), so one cannot fill the queue; Put blocks while
+// its tenant is at its cap.
+//
+// Close semantics match Queue. All methods are safe for concurrent use by
+// multiple goroutines.
+type FairQueue[K comparable, T comparable] struct {
+    mu      sync.Mutex
+    takers  waitList[T]
+    tenants map[K]*tenant[K, T]
+    active  []*tenant[K, T] // tenants with values, in round-robin order
+    weights map[K]int
+    caps    map[K]int
+    dedup   bool
+    o       fairOptions
+    cost    func(v T) int // nil for weighted round-robin
+    quantum int
+    closed  bool
+}
+
+// tenant is the state of one tenant key.
+type tenant[K comparable, T comparable] struct {
+    key      K
+    q        *base.Queue[T]
+    putters  waitList[T] // producers waiting for the tenant to drop below its cap
+    weight   int
+    limit    int  // at most this many values queued; 0 means no limit
+    deficit  int  // credit left in the current round
+    visiting bool // the tenant is at the front and received its credit
+    active   bool // the tenant is in FairQueue.active
+}
+
+// FairOption configures a FairQueue. Pass options to NewFairQueue.
+type FairOption func(*fairOptions)
+
+type fairOptions struct {
+    queue []base.Option
+    cap   int
+}
+
+// WithTenantOptions passes options through to every tenant's
+// xyqueue.Queue, for example a TTL.
+func WithTenantOptions(opts ...base.Option) FairOption {
+    return func(o *fairOptions) { o.queue = append(o.queue, opts...) }
+}
+
+// WithTenantCap limits every tenant to at most n queued values; n <= 0 means
+// no limit, the default. SetCap overrides it for one tenant.
+func WithTenantCap(n int) FairOption {
+    return func(o *fairOptions) { o.cap = n }
+}
+
+// NewFairQueue creates a fair queue. With dedup each tenant's sub-queue
+// skips values it already holds; tenants do not de-duplicate against each
+// other.
+func NewFairQueue[K comparable, T comparable](dedup bool, opts ...FairOption) *FairQueue[K, T] {
+    var o fairOptions
+    for _, opt := range opts {
+        opt(&o)
+    }
+    return &FairQueue[K, T]{
+        tenants: make(map[K]*tenant[K, T]),
+        weights: make(map[K]int),
+        caps:    make(map[K]int),
+        dedup:   dedup,
+        o:       o,
+        quantum: 1,
+    }
+}
+
+// SetCost schedules tenants by deficit round-robin: each round a tenant of
+// weight w earns w*quantum credit and is served while its next value costs
+// no more than the credit it has, as measured by cost. Use it when values
+// differ in size, so tenants get a fair share of the work rather than of
+// the values; quantum should be about the cost of a large value, and is
+// treated as 1 if smaller. cost must be non-negative. A nil cost switches
+// back to weighted round-robin, the default.
+func (f *FairQueue[K, T]) SetCost(cost func(v T) int, quantum int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.cost = cost
+    f.quantum = 1
+    if cost != nil {
+        f.quantum = max(quantum, 1)
+    }
+}
+
+// SetWeight sets the share of tenant k: per round it gets w times the share
+// of a tenant of weight 1, the default. w < 1 is treated as 1.
+func (f *FairQueue[K, T]) SetWeight(k K, w int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    w = max(w, 1)
+    if w == 1 {
+        delete(f.weights, k)
+    } else {
+        f.weights[k] = w
+    }
+    if t := f.tenants[k]; t != nil {
+        t.weight = w
+    }
+}
+
+// SetCap limits tenant k to at most n queued values, overriding
+// WithTenantCap; n <= 0 means no limit. Lowering the cap removes nothing.
+func (f *FairQueue[K, T]) SetCap(k K, n int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    n = max(n, 0)
+    f.caps[k] = n
+    if t := f.tenants[k]; t != nil {
+        t.limit = n
+        t.putters.wakeAll()
+    }
+}
+
+// Put adds v to tenant k's sub-queue, blocking while the tenant is at its
+// cap. Returns true if v was added, or false when de-duplication skipped it
+// or the queue is closed.
+func (f *FairQueue[K, T]) Put(k K, v T) bool {
+    added, _ := f.PutContext(context.Background(), k, v)
+    return added
+}
+
+// PutContext is Put that gives up when ctx is done. Results are as for
+// Queue.PutContext.
+func (f *FairQueue[K, T]) PutContext(ctx context.Context, k K, v T) (bool, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for {
+        if f.closed {
+            return false, ErrClosed
+        }
+        t := f.tenant(k)
+        if r := f.offer(t, v); r != base.Full {
+            f.release(t)
+            return r == base.Added, nil
+        }
+        if err := ctx.Err(); err != nil {
+            return false, err
+        }
+        if next, ok := t.q.NextExpiry(); ok {
+            // A value expiring frees room under the cap.
+            timer := time.NewTimer(time.Until(next))
+            parkUntil(ctx, &f.mu, &t.putters, timer.C)
+            timer.Stop()
+        } else {
+            park(ctx, &f.mu, &t.putters)
+        }
+        f.release(t)
+    }
+}
+
+// TryPut is Put without blocking: it returns ErrFull when tenant k is at its
+// cap and ErrClosed when the queue is closed.
+func (f *FairQueue[K, T]) TryPut(k K, v T) (bool, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if f.closed {
+        return false, ErrClosed
+    }
+    t := f.tenant(k)
+    r := f.offer(t, v)
+    f.release(t)
+    switch r {
+    case base.Added:
+        return true, nil
+    case base.Full:
+        return false, ErrFull
+    }
+    return false, nil
+}
+
+// Take blocks until a value is available or ctx is done, then removes and
+// returns the value of the tenant whose turn it is. Errors are those of
+// Queue.Take.
+func (f *FairQueue[K, T]) Take(ctx context.Context) (T, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for {
+        if v, ok := f.next(); ok {
+            return v, nil
+        }
+        var zero T
+        if f.closed {
+            return zero, ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return zero, err
+        }
+        park(ctx, &f.mu, &f.takers)
+    }
+}
+
+// TryTake is Take without blocking. ok is false if the queue is empty.
+func (f *FairQueue[K, T]) TryTake() (v T, ok bool) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.next()
+}
+
+// Len returns the number of values queued across all tenants.
+func (f *FairQueue[K, T]) Len() int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    n := 0
+    for _, t := range f.active {
+        n += t.q.Len()
+    }
+    return n
+}
+
+// TenantLen returns the number of values queued for tenant k.
+func (f *FairQueue[K, T]) TenantLen(k K) int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if t := f.tenants[k]; t != nil {
+        return t.q.Len()
+    }
+    return 0
+}
+
+// Tenants returns the number of tenants with values queued.
+func (f *FairQueue[K, T]) Tenants() int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    n := 0
+    for _, t := range f.active {
+        if t.q.Len() > 0 {
+            n++
+        }
+    }
+    return n
+}
+
+// Close marks the queue closed, as Queue.Close does: puts fail with
+// ErrClosed, and Take drains the remaining values and then returns ErrClosed.
+func (f *FairQueue[K, T]) Close() {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.closed = true
+    f.takers.wakeAll()
+    for _, t := range f.tenants {
+        t.putters.wakeAll()
+    }
+}
+
+// CloseNow closes the queue like Close and discards every value.
+func (f *FairQueue[K, T]) CloseNow() {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.closed = true
+    f.takers.wakeAll()
+    for k, t := range f.tenants {
+        t.putters.wakeAll()
+        delete(f.tenants, k)
+    }
+    clear(f.active)
+    f.active = f.active[:0]
+}
+
+// IsClosed reports whether Close or CloseNow has been called.
+func (f *FairQueue[K, T]) IsClosed() bool {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.closed
+}
+
+// tenant returns the state of tenant k, creating it if needed. f.mu must be
+// held.
+func (f *FairQueue[K, T]) tenant(k K) *tenant[K, T] {
+    t := f.tenants[k]
+    if t == nil {
+        limit, ok := f.caps[k]
+        if !ok {
+            limit = f.o.cap
+        }
+        t = &tenant[K, T]{
+            key:    k,
+            q:      base.New[T](f.dedup, f.o.queue...),
+            weight: max(f.weights[k], 1),
+            limit:  max(limit, 0),
+        }
+        f.tenants[k] = t
+    }
+    return t
+}
+
+// release forgets tenant t once it holds nothing and nobody waits on it, so
+// that short-lived tenants do not accumulate. f.mu must be held.
+func (f *FairQueue[K, T]) release(t *tenant[K, T]) {
+    if !t.active && t.putters.n == 0 && t.q.Len() == 0 && f.tenants[t.key] == t {
+        delete(f.tenants, t.key)
+    }
+}
+
+// offer adds v to tenant t unless it is at its cap, and wakes a consumer
+// when v was added. f.mu must be held.
+func (f *FairQueue[K, T]) offer(t *tenant[K, T], v T) base.Result {
+    if t.limit > 0 && t.q.Len() >= t.limit {
+        if f.dedup && t.q.Contains(v) {
+            return base.Duplicate
+        }
+        return base.Full
+    }
+    r := t.q.Offer(v)
+    if r == base.Added {
+        if !t.active {
+            t.active, t.visiting = true, false
+            f.active = append(f.active, t)
+        }
+        f.takers.wake(1)
+    }
+    return r
+}
+
+// next removes and returns the next value in round-robin order. f.mu must be
+// held.
+func (f *FairQueue[K, T]) next() (T, bool) {
+    for len(f.active) > 0 {
+        t := f.active[0]
+        head, ok := t.q.Peek()
+        if !ok {
+            // Emptied without Take, for example by expiry.
+            f.retire(t)
+            continue
+        }
+        if !t.visiting {
+            t.visiting = true
+            t.deficit += t.weight * f.quantum
+        }
+        c := 1
+        if f.cost != nil {
+            c = f.cost(head)
+        }
+        if c > t.deficit {
+            // The tenant's turn is over; its credit carries over.
+            t.visiting = false
+            f.active = append(f.active[1:], t)
+            continue
+        }
+        t.deficit -= c
+        t.q.Dequeue()
+        t.putters.wake(1)
+        if t.q.Len() == 0 {
+            f.retire(t)
+        }
+        return head, true
+    }
+    var zero T
+    return zero, false
+}
+
+// retire takes the tenant at the front out of the rotation once it has no
+// values left; its unused credit is dropped, as deficit round-robin
+// requires. f.mu must be held.
+func (f *FairQueue[K, T]) retire(t *tenant[K, T]) {
+    f.active[0] = nil
+    f.active = f.active[1:]
+    t.active, t.visiting, t.deficit = false, false, 0
+    f.release(t)
+}
@@ -0,0 +1,211 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+func TestFairQueueRoundRobin(t *testing.T) {
+    f := NewFairQueue[string, int](false)
+    for i := 1; i <= 4; i++ {
+        f.Put("noisy", i)
+    }
+    f.Put("quiet", 100)
+    f.Put("other", 200)
+
+    want := []int{1, 100, 200, 2, 3, 4}
+    for _, w := range want {
+        v, ok := f.TryTake()
+        if !ok || v != w {
+            t.Fatalf("TryTake() = %d, %v, want %d", v, ok, w)
+        }
+    }
+    if f.Len() != 0 || f.Tenants() != 0 {
+        t.Fatalf("Len() = %d, Tenants() = %d, want 0, 0", f.Len(), f.Tenants())
+    }
+}
+
+func TestFairQueueWeights(t *testing.T) {
+    f := NewFairQueue[string, int](false)
+    f.SetWeight("a", 3)
+    for i := 0; i < 6; i++ {
+        f.Put("a", i)
+        f.Put("b", 10+i)
+    }
+    want := []int{0, 1, 2, 10, 3, 4, 5, 11}
+    for _, w := range want {
+        if v, _ := f.TryTake(); v != w {
+            t.Fatalf("TryTake() = %d, want %d", v, w)
+        }
+    }
+}
+
+func TestFairQueueDeficitRoundRobin(t *testing.T) {
+    // Values are their own cost: tenant "big" sends one expensive value,
+    // "small" many cheap ones, and both get about the same work per round.
+    f := NewFairQueue[string, int](false)
+    f.SetCost(func(v int) int { return v }, 4)
+    f.Put("big", 8)
+    f.Put("big", 8)
+    for i := 0; i < 8; i++ {
+        f.Put("small", 1)
+    }
+    var got []int
+    for f.Len() > 0 {
+        v, _ := f.TryTake()
+        got = append(got, v)
+    }
+    // Round 1: big has 4 credit (too little), small spends 4.
+    // Round 2: big has 8 and spends it, small spends 4. Round 3: big.
+    want := []int{1, 1, 1, 1, 8, 1, 1, 1, 1, 8}
+    if len(got) != len(want) {
+        t.Fatalf("took %v, want %v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("took %v, want %v", got, want)
+        }
+    }
+
+    // Back to weighted round-robin: every value costs 1 again.
+    f.SetCost(nil, 0)
+    f.Put("big", 8)
+    f.Put("small", 1)
+    if v, _ := f.TryTake(); v != 8 {
+        t.Fatalf("TryTake() = %d after SetCost(nil), want 8", v)
+    }
+}
+
+func TestFairQueueDedupPerTenant(t *testing.T) {
+    f := NewFairQueue[string, string](true)
+    if !f.Put("a", "x") || f.Put("a", "x") {
+        t.Fatal("de-duplication within a tenant failed")
+    }
+    if !f.Put("b", "x") {
+        t.Fatal("tenants must not de-duplicate against each other")
+    }
+    if f.Len() != 2 || f.TenantLen("a") != 1 {
+        t.Fatalf("Len() = %d, TenantLen(a) = %d, want 2, 1", f.Len(), f.TenantLen("a"))
+    }
+}
+
+func TestFairQueueCap(t *testing.T) {
+    f := NewFairQueue[string, int](false, WithTenantCap(2))
+    f.SetCap("vip", 0)
+    f.Put("a", 1)
+    f.Put("a", 2)
+    if _, err := f.TryPut("a", 3); !errors.Is(err, ErrFull) {
+        t.Fatalf("TryPut over cap = %v, want ErrFull", err)
+    }
+    if added, err := f.TryPut("b", 3); !added || err != nil {
+        t.Fatalf("TryPut for another tenant = %v, %v", added, err)
+    }
+    for i := 0; i < 5; i++ {
+        if added, err := f.TryPut("vip", i); !added || err != nil {
+            t.Fatalf("TryPut for uncapped tenant = %v, %v", added, err)
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if _, err := f.PutContext(ctx, "a", 3); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("PutContext over cap = %v, want DeadlineExceeded", err)
+    }
+
+    done := make(chan bool, 1)
+    go func() { done <- f.Put("a", 3) }()
+    select {
+    case <-done:
+        t.Fatal("Put over cap did not block")
+    case <-time.After(20 * time.Millisecond):
+    }
+    if v, _ := f.TryTake(); v != 1 {
+        t.Fatalf("TryTake() = %d, want 1", v)
+    }
+    if !<-done {
+        t.Fatal("blocked Put did not add after room was made")
+    }
+    if f.TenantLen("a") != 2 {
+        t.Fatalf("TenantLen(a) = %d, want 2", f.TenantLen("a"))
+    }
+}
+
+func TestFairQueueCapExpiry(t *testing.T) {
+    f := NewFairQueue[string, int](false, WithTenantCap(1), WithTenantOptions(base.WithTTL(10*time.Millisecond)))
+    f.Put("a", 1)
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    start := time.Now()
+    if added, err := f.PutContext(ctx, "a", 2); !added || err != nil {
+        t.Fatalf("PutContext after the value expired = %v, %v, want true, nil", added, err)
+    }
+    if d := time.Since(start); d > 500*time.Millisecond {
+        t.Fatalf("blocked Put took %v to notice the expiry", d)
+    }
+    if v, _ := f.TryTake(); v != 2 {
+        t.Fatalf("TryTake() = %d, want 2", v)
+    }
+}
+
+func TestFairQueueTakeBlocks(t *testing.T) {
+    f := NewFairQueue[int, string](false)
+    res := make(chan string, 1)
+    go func() {
+        v, err := f.Take(context.Background())
+        if err != nil {
+            t.Error(err)
+        }
+        res <- v
+    }()
+    time.Sleep(10 * time.Millisecond)
+    f.Put(7, "job")
+    select {
+    case v := <-res:
+        if v != "job" {
+            t.Fatalf("Take() = %q, want job", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("Take was not woken by Put")
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if _, err := f.Take(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Take on empty queue = %v, want DeadlineExceeded", err)
+    }
+}
+
+func TestFairQueueClose(t *testing.T) {
+    f := NewFairQueue[string, int](false)
+    f.Put("a", 1)
+    f.Close()
+    if f.Put("a", 2) {
+        t.Fatal("Put after Close added")
+    }
+    if v, err := f.Take(context.Background()); v != 1 || err != nil {
+        t.Fatalf("Take() = %d, %v, want 1, nil", v, err)
+    }
+    if _, err := f.Take(context.Background()); !errors.Is(err, ErrClosed) {
+        t.Fatalf("Take on drained queue = %v, want ErrClosed", err)
+    }
+
+    g := NewFairQueue[string, int](false, WithTenantCap(1))
+    g.Put("a", 1)
+    done := make(chan error, 1)
+    go func() {
+        _, err := g.PutContext(context.Background(), "a", 2)
+        done <- err
+    }()
+    time.Sleep(10 * time.Millisecond)
+    g.CloseNow()
+    if err := <-done; !errors.Is(err, ErrClosed) {
+        t.Fatalf("blocked Put after CloseNow = %v, want ErrClosed", err)
+    }
+    if g.Len() != 0 || !g.IsClosed() {
+        t.Fatalf("Len() = %d, IsClosed() = %v after CloseNow", g.Len(), g.IsClosed())
+    }
+}
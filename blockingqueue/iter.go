@@ -0,0 +1,52 @@
+package blockingqueue
+
+import (
+    "context"
+    "iter"
+)
+
+// All returns an iterator over the queued values in FIFO order without
+// removing them. It does not block; see xyqueue.Queue.All for its
+// consistency guarantees.
+func (b *Queue[T]) All() iter.Seq[T] {
+    return b.q.All()
+}
+
+// Drain returns an iterator that removes and yields values until the queue is
+// empty or the loop stops. It never blocks; use Stream to wait for new values.
+func (b *Queue[T]) Drain() iter.Seq[T] {
+    return func(yield func(T) bool) {
+        for {
+            v, ok := b.TryTake()
+            if !ok || !yield(v) {
+                return
+            }
+        }
+    }
+}
+
+// Stream returns an iterator that takes values as they arrive, blocking while
+// the queue is empty. It ends without error once the queue is closed and
+// drained. If ctx ends first, the final pair yielded is the zero value and the
+// context error. Breaking out of the loop stops taking; the value just
+// received is not returned to the queue.
+//
+//	for v, err := range q.Stream(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		handle(v)
+//	}
+func (b *Queue[T]) Stream(ctx context.Context) iter.Seq2[T, error] {
+    return func(yield func(T, error) bool) {
+        for {
+            v, err := b.Take(ctx)
+            if IsClosedError(err) {
+                return
+            }
+            if !yield(v, err) || err != nil {
+                return
+            }
+        }
+    }
+}
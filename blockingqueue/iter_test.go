@@ -0,0 +1,71 @@
+package blockingqueue
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestStreamEndsOnClose(t *testing.T) {
+    bq := New[int](false)
+    go func() {
+        for i := 1; i <= 3; i++ {
+            bq.Put(i)
+            time.Sleep(time.Millisecond)
+        }
+        bq.Close()
+    }()
+    sum := 0
+    for v, err := range bq.Stream(context.Background()) {
+        if err != nil {
+            t.Fatalf("unexpected err: %v", err)
+        }
+        sum += v
+    }
+    if sum != 6 {
+        t.Fatalf("sum=%d want 6", sum)
+    }
+}
+
+func TestStreamContextError(t *testing.T) {
+    bq := New[int](false)
+    bq.Put(1)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    var got []int
+    var last error
+    for v, err := range bq.Stream(ctx) {
+        if err != nil {
+            last = err
+            continue // the iterator stops after yielding the error
+        }
+        got = append(got, v)
+    }
+    if len(got) != 1 || !IsContextError(last) {
+        t.Fatalf("got %v, last err %v", got, last)
+    }
+}
+
+func TestDrainAndAll(t *testing.T) {
+    bq := New[int](false, WithMaxSize(3))
+    bq.PutMany(1, 2, 3)
+    n := 0
+    for range bq.All() {
+        n++
+    }
+    if n != 3 || bq.Len() != 3 {
+        t.Fatalf("All visited %d, len %d", n, bq.Len())
+    }
+    for v := range bq.Drain() {
+        if v == 2 {
+            break
+        }
+    }
+    if bq.Len() != 1 {
+        t.Fatalf("len=%d want 1 after partial drain", bq.Len())
+    }
+    // Draining freed space for producers.
+    if ok, err := bq.TryPut(4); !ok || err != nil {
+        t.Fatalf("tryput got (%v,%v)", ok, err)
+    }
+}
@@ -0,0 +1,84 @@
+package blockingqueue
+
+import "context"
+
+// TaskDone reports that a value taken from the queue has been processed, like
+// Python's queue.Queue.task_done. Every value returned by Take, TryTake,
+// TakeBatch or TakeIf must be followed by one call to TaskDone or Done,
+// whether or not processing succeeded, so that Join can tell when all work is
+// finished. Values reserved with Reserve are instead finished by their lease
+// (see Unfinished). TaskDone panics if called more times than values were
+// taken.
+func (b *Queue[T]) TaskDone() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.taskDone()
+}
+
+// Done is TaskDone for a value v whose processing succeeded: it also forgets
+// the failures counted for v (see Retry), as acknowledging a lease does. After
+// handing v to Retry, call TaskDone instead, so its failures keep counting.
+func (b *Queue[T]) Done(v T) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    delete(b.attempts, v)
+    b.taskDone()
+}
+
+// Unfinished returns the number of values put that are not finished yet:
+// those queued, leased, awaiting a retry, or taken and not yet reported done
+// with TaskDone or Done. A leased value is finished once its lease is
+// acknowledged or its value dead-lettered; a value removed from the queue
+// without being taken, as by Remove or Clear, or dropped because its TTL
+// elapsed, is finished too.
+func (b *Queue[T]) Unfinished() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.reclaim()
+    return b.unfinished()
+}
+
+// Join blocks until every value put has been finished (see Unfinished) or
+// ctx is done, in which case it returns ctx.Err(). Join works across Close;
+// it returns as soon as the count reaches zero, even if more values are put
+// later.
+func (b *Queue[T]) Join(ctx context.Context) error {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for {
+        b.reclaim()
+        if b.unfinished() == 0 {
+            return nil
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        b.wait(ctx, &b.joiners)
+    }
+}
+
+// taskDone counts one taken value as processed. b.mu must be held.
+func (b *Queue[T]) taskDone() {
+    if b.taken == 0 {
+        panic("blockingqueue: TaskDone called more times than values were taken")
+    }
+    b.taken--
+    b.checkJoin()
+}
+
+// unfinished returns the number of values not finished yet. b.mu must be
+// held.
+func (b *Queue[T]) unfinished() int {
+    return b.q.Len() + len(b.leases) + len(b.retries) + b.taken
+}
+
+// checkJoin wakes every Join caller once no value is left unfinished. b.mu
+// must be held.
+func (b *Queue[T]) checkJoin() {
+    if b.joiners.n > 0 && b.unfinished() == 0 {
+        b.joiners.wakeAll()
+    }
+}
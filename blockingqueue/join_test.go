@@ -0,0 +1,157 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+func TestJoinWaitsForTaskDone(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 2, 3)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if err := bq.Join(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Join with unfinished values = %v want DeadlineExceeded", err)
+    }
+
+    var processed sync.WaitGroup
+    processed.Add(3)
+    go func() {
+        for i := range 3 {
+            v, _ := bq.Take(context.Background())
+            if bq.Unfinished() != 3-i {
+                t.Errorf("a taken value must stay unfinished until reported done")
+            }
+            processed.Done()
+            bq.Done(v)
+        }
+    }()
+    if err := bq.Join(context.Background()); err != nil {
+        t.Fatalf("Join: %v", err)
+    }
+    processed.Wait()
+    if bq.Unfinished() != 0 {
+        t.Fatalf("Unfinished = %d want 0", bq.Unfinished())
+    }
+    defer func() {
+        if recover() == nil {
+            t.Fatal("an extra TaskDone must panic")
+        }
+    }()
+    bq.TaskDone()
+}
+
+func TestUnfinishedCountsLeasesAndRetries(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithBackoff(Backoff{Initial: time.Second}))
+    bq.PutMany(1, 2, 3)
+    l, _ := bq.TryReserve()
+    v, _ := bq.TryTake()
+    bq.Retry(v, nil)
+    bq.TaskDone()
+    bq.Remove(3)
+    if n := bq.Unfinished(); n != 2 {
+        t.Fatalf("Unfinished = %d want 2 (one leased, one awaiting retry)", n)
+    }
+    done := make(chan error, 1)
+    go func() { done <- bq.Join(context.Background()) }()
+    l.Ack()
+    clk.Advance(time.Second)
+    v, _ = bq.TryTake()
+    select {
+    case <-done:
+        t.Fatal("Join returned while a value was still being processed")
+    case <-time.After(10 * time.Millisecond):
+    }
+    bq.Done(v)
+    select {
+    case err := <-done:
+        if err != nil {
+            t.Fatalf("Join: %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("Join not woken when the last value was done")
+    }
+}
+
+func TestJoinAfterRetryDeadLetters(t *testing.T) {
+    bq := New[string](false, WithDeadLetter(1))
+    bq.Put("a")
+    l, _ := bq.TryReserve()
+    done := make(chan error, 1)
+    go func() { done <- bq.Join(context.Background()) }()
+    time.Sleep(10 * time.Millisecond)
+    if err := l.Retry(errors.New("boom")); err != nil {
+        t.Fatalf("Retry: %v", err)
+    }
+    select {
+    case err := <-done:
+        if err != nil {
+            t.Fatalf("Join: %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("Join not woken when the last lease was dead-lettered")
+    }
+}
+
+func TestJoinAfterExpiry(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[string](false, WithClock(clk), WithQueueOptions(base.WithTTL(time.Minute)))
+    bq.Put("a")
+    done := make(chan error, 1)
+    go func() { done <- bq.Join(context.Background()) }()
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Minute)
+    select {
+    case err := <-done:
+        if err != nil {
+            t.Fatalf("Join: %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("Join not woken when the last value expired")
+    }
+    if bq.Unfinished() != 0 {
+        t.Fatalf("Unfinished = %d want 0", bq.Unfinished())
+    }
+}
+
+func TestJoinAfterRequeue(t *testing.T) {
+    bq := New[string](false)
+    bq.Put("a")
+    v, _ := bq.TryTake()
+    if ok, err := bq.Requeue(v); !ok || err != nil {
+        t.Fatalf("Requeue = %v,%v", ok, err)
+    }
+    if bq.Unfinished() != 2 {
+        t.Fatalf("Unfinished = %d want 2: queued again and still taken", bq.Unfinished())
+    }
+    bq.TaskDone()
+    if bq.Unfinished() != 1 {
+        t.Fatalf("Unfinished = %d want 1 after TaskDone", bq.Unfinished())
+    }
+
+    v, _ = bq.TryTake()
+    bq.Done(v)
+
+    // Requeueing a value that was never taken must not finish the take of
+    // another.
+    bq.Put("b")
+    v, _ = bq.TryTake()
+    bq.Requeue("c")
+    bq.TaskDone()
+    if bq.Unfinished() != 1 {
+        t.Fatalf("Unfinished = %d want 1: only c is left", bq.Unfinished())
+    }
+    v, _ = bq.TryTake()
+    bq.Done(v)
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    if err := bq.Join(ctx); err != nil {
+        t.Fatalf("Join: %v", err)
+    }
+}
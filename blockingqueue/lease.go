@@ -0,0 +1,286 @@
+package blockingqueue
+
+import (
+    "container/heap"
+    "context"
+    "errors"
+    "time"
+)
+
+// DefaultVisibilityTimeout is how long a reserved value stays invisible to
+// other consumers unless WithVisibilityTimeout says otherwise.
+const DefaultVisibilityTimeout = 30 * time.Second
+
+// ErrLeaseExpired is returned by the methods of a Lease that is no longer
+// held: it was already acknowledged or returned, its visibility timeout
+// elapsed so its value went back to the queue, or the queue was closed with
+// CloseNow.
+var ErrLeaseExpired = errors.New("blockingqueue: lease expired")
+
+// Lease is a value reserved with Reserve, for at-least-once delivery. The
+// value stays out of the queue while the lease is held; the consumer settles
+// the lease with Ack once the value has been processed, or with Nack to hand
+// it back. A lease that is not settled within the visibility timeout expires
+// and its value returns to the head of the queue for another consumer.
+//
+// Lease methods are safe for concurrent use.
+type Lease[T comparable] struct {
+    b        *Queue[T]
+    v        T
+    deadline time.Time
+    attempt  int
+    i        int // position in b.leases, -1 once the lease is no longer held
+}
+
+// Value returns the reserved value.
+func (l *Lease[T]) Value() T { return l.v }
+
+// Attempt returns how many times the value has been delivered, counting this
+// delivery: 1 the first time it is reserved, 2 after one failed delivery,
+// and so on. Failures are counted per value (see Fail).
+func (l *Lease[T]) Attempt() int { return l.attempt }
+
+// Deadline returns the time at which the lease expires.
+func (l *Lease[T]) Deadline() time.Time {
+    l.b.mu.Lock()
+    defer l.b.mu.Unlock()
+    return l.deadline
+}
+
+// Ack settles the lease: the value has been processed and is gone for good.
+// Returns ErrLeaseExpired if the lease is no longer held, in which case the
+// value may be delivered again.
+func (l *Lease[T]) Ack() error {
+    b := l.b
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if !b.held(l) {
+        return ErrLeaseExpired
+    }
+    delete(b.attempts, l.v)
+    b.release(l)
+    b.checkJoin()
+    return nil
+}
+
+// Nack settles the lease as a failed delivery without an error; see Fail.
+func (l *Lease[T]) Nack() error {
+    return l.Fail(nil)
+}
+
+// Fail settles the lease as a failed delivery: the value returns to the head
+// of the queue, so it is delivered again right away, unless it has now been
+// delivered as often as WithDeadLetter allows, in which case it moves to the
+// dead-letter queue with err attached. Returns ErrLeaseExpired if the lease
+// is no longer held, or ErrFull if a bounded queue has no room for the value,
+// in which case the lease is still held.
+func (l *Lease[T]) Fail(err error) error {
+    b := l.b
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if !b.held(l) {
+        return ErrLeaseExpired
+    }
+    if !b.fail(l, err) {
+        return ErrFull
+    }
+    return nil
+}
+
+// Extend pushes the lease's deadline to d from now, for a consumer that
+// needs more time than the visibility timeout. Returns ErrLeaseExpired if the
+// lease is no longer held.
+func (l *Lease[T]) Extend(d time.Duration) error {
+    b := l.b
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if !b.held(l) {
+        return ErrLeaseExpired
+    }
+    l.deadline = b.clock.Now().Add(d)
+    heap.Fix(&b.leases, l.i)
+    if l.i == 0 {
+        b.rearm()
+    }
+    return nil
+}
+
+// Reserve blocks like Take until a value is available or ctx is done, then
+// leases it to the caller for the queue's visibility timeout (see
+// WithVisibilityTimeout) instead of removing it for good. Errors are those of
+// Take. A lease that expires counts as a failed delivery with error
+// ErrLeaseExpired.
+//
+// While leased, the value counts as present for de-duplication, so Put
+// cannot add it a second time, but it no longer occupies space in a bounded
+// queue.
+func (b *Queue[T]) Reserve(ctx context.Context) (*Lease[T], error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for {
+        b.reclaim()
+        if v, ok := b.q.Dequeue(); ok {
+            b.putters.wake(1)
+            return b.lease(v), nil
+        }
+        if b.drained() {
+            return nil, ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+        if v, ok := b.wait(ctx, &b.takers); ok {
+            return b.lease(v), nil
+        }
+    }
+}
+
+// TryReserve is Reserve without blocking. ok is false if the queue is empty.
+func (b *Queue[T]) TryReserve() (l *Lease[T], ok bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.reclaim()
+    v, ok := b.q.Dequeue()
+    if !ok {
+        return nil, false
+    }
+    b.putters.wake(1)
+    return b.lease(v), true
+}
+
+// InFlight returns the number of values currently leased.
+func (b *Queue[T]) InFlight() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.reclaim()
+    return len(b.leases)
+}
+
+// lease hands v out under a new lease. b.mu must be held.
+func (b *Queue[T]) lease(v T) *Lease[T] {
+    l := &Lease[T]{b: b, v: v, deadline: b.clock.Now().Add(b.visibility), attempt: b.attempts[v] + 1}
+    heap.Push(&b.leases, l)
+    b.setOutside(v, true)
+    if l.i == 0 {
+        b.rearm()
+    }
+    return l
+}
+
+// held reports whether l is still held, first returning expired leases to
+// the queue. b.mu must be held.
+func (b *Queue[T]) held(l *Lease[T]) bool {
+    b.reclaim()
+    return l.i >= 0
+}
+
+// release forgets l. b.mu must be held.
+func (b *Queue[T]) release(l *Lease[T]) {
+    heap.Remove(&b.leases, l.i)
+    b.setOutside(l.v, false)
+    b.checkDrained()
+}
+
+// checkDrained wakes every consumer once a closed queue is drained (see
+// drained), so those waiting for values to come back report ErrClosed. b.mu
+// must be held.
+func (b *Queue[T]) checkDrained() {
+    if b.drained() {
+        b.takers.wakeAll()
+        b.matchers.wakeAll()
+    }
+}
+
+// rearm wakes a consumer and every TakeIf caller after the earliest lease
+// deadline or retry due time moved, so that they wait for the new one. b.mu
+// must be held.
+func (b *Queue[T]) rearm() {
+    b.takers.wake(1)
+    b.matchers.wakeAll()
+}
+
+// reclaim settles expired leases as failed deliveries and moves retries that
+// fell due into the queue. A value that does not fit in a full bounded queue
+// stays leased for another visibility timeout, or stays pending until there
+// is room. b.mu must be held.
+func (b *Queue[T]) reclaim() {
+    if len(b.leases) == 0 && len(b.retries) == 0 {
+        return
+    }
+    now := b.clock.Now()
+    b.promote(now)
+    // Each expired lease is handled once: an extended lease is not due again
+    // before now, but the count bounds the loop regardless.
+    for n := len(b.leases); n > 0 && len(b.leases) > 0 && !b.leases[0].deadline.After(now); n-- {
+        l := b.leases[0]
+        if !b.fail(l, ErrLeaseExpired) {
+            l.deadline = now.Add(b.visibility)
+            heap.Fix(&b.leases, 0)
+        }
+    }
+}
+
+// wait parks the caller on l like park, but when leases are outstanding,
+// retries pending or values due to expire it also wakes at the earliest lease
+// deadline, retry due time or expiry, so the value can be reclaimed or
+// purged. b.mu must be held.
+func (b *Queue[T]) wait(ctx context.Context, l *waitList[T]) (T, bool) {
+    d, ok := b.alarm()
+    if !ok {
+        return park(ctx, &b.mu, l)
+    }
+    timer := b.clock.NewTimer(d)
+    defer timer.Stop()
+    return parkUntil(ctx, &b.mu, l, timer.C())
+}
+
+// alarm returns how long until the earliest lease deadline, retry due time
+// or TTL expiry, if any; expiry finishes values and frees room. b.mu must be
+// held.
+func (b *Queue[T]) alarm() (time.Duration, bool) {
+    now := b.clock.Now()
+    next, _ := b.q.NextExpiry()
+    if len(b.leases) > 0 && (next.IsZero() || b.leases[0].deadline.Before(next)) {
+        next = b.leases[0].deadline
+    }
+    // A retry already due is only pending for lack of room; the consumer
+    // that makes room promotes it.
+    if len(b.retries) > 0 && b.retries[0].due.After(now) && (next.IsZero() || b.retries[0].due.Before(next)) {
+        next = b.retries[0].due
+    }
+    if next.IsZero() {
+        return 0, false
+    }
+    return next.Sub(now), true
+}
+
+// leaseHeap orders outstanding leases by deadline; it implements
+// heap.Interface.
+type leaseHeap[T comparable] []*Lease[T]
+
+func (h leaseHeap[T]) Len() int { return len(h) }
+
+func (h leaseHeap[T]) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+
+func (h leaseHeap[T]) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].i, h[j].i = i, j
+}
+
+func (h *leaseHeap[T]) Push(x any) {
+    l := x.(*Lease[T])
+    l.i = len(*h)
+    *h = append(*h, l)
+}
+
+func (h *leaseHeap[T]) Pop() any {
+    old := *h
+    l := old[len(old)-1]
+    old[len(old)-1] = nil
+    *h = old[:len(old)-1]
+    l.i = -1
+    return l
+}
@@ -0,0 +1,154 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+func TestReserveAckNack(t *testing.T) {
+    bq := New[string](true)
+    bq.PutMany("a", "b")
+    l, err := bq.Reserve(context.Background())
+    if err != nil || l.Value() != "a" {
+        t.Fatalf("Reserve = %v,%v want a", l, err)
+    }
+    if bq.Len() != 1 || bq.InFlight() != 1 || bq.Contains("a") {
+        t.Fatalf("Len=%d InFlight=%d: a must be leased, not queued", bq.Len(), bq.InFlight())
+    }
+    if bq.Put("a") {
+        t.Fatal("a leased value must count as present for dedup")
+    }
+    if added, err := bq.Requeue("a"); added || err != nil {
+        t.Fatalf("Requeue(leased) = %v,%v want false,nil", added, err)
+    }
+    if err := l.Nack(); err != nil {
+        t.Fatalf("Nack: %v", err)
+    }
+    if v, _ := bq.Peek(); v != "a" || bq.InFlight() != 0 {
+        t.Fatalf("Nack must return a to the head, got head %q", v)
+    }
+    if err := l.Ack(); !errors.Is(err, ErrLeaseExpired) {
+        t.Fatalf("Ack after Nack = %v want ErrLeaseExpired", err)
+    }
+    l, ok := bq.TryReserve()
+    if !ok || l.Value() != "a" {
+        t.Fatal("TryReserve should lease a again")
+    }
+    if err := l.Ack(); err != nil {
+        t.Fatalf("Ack: %v", err)
+    }
+    if !bq.Put("a") {
+        t.Fatal("an acknowledged value must be enqueueable again")
+    }
+}
+
+func TestLeaseExpires(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithVisibilityTimeout(time.Minute))
+    bq.PutMany(1, 2)
+    l, _ := bq.TryReserve()
+    if !l.Deadline().Equal(clk.Now().Add(time.Minute)) {
+        t.Fatalf("Deadline = %v", l.Deadline())
+    }
+    if err := l.Extend(2 * time.Minute); err != nil {
+        t.Fatalf("Extend: %v", err)
+    }
+    clk.Advance(time.Minute)
+    if bq.InFlight() != 1 {
+        t.Fatal("an extended lease must not expire early")
+    }
+    clk.Advance(time.Minute)
+    if v, ok := bq.TryTake(); !ok || v != 1 {
+        t.Fatalf("TryTake = %d,%v: the expired value must return to the head", v, ok)
+    }
+    for _, err := range []error{l.Ack(), l.Nack(), l.Extend(time.Minute)} {
+        if !errors.Is(err, ErrLeaseExpired) {
+            t.Fatalf("settling an expired lease = %v want ErrLeaseExpired", err)
+        }
+    }
+}
+
+func TestExpiredLeaseOnFullQueue(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithVisibilityTimeout(0),
+        WithQueueOptions(base.WithMaxSize(1, base.Reject)))
+    bq.Put(1)
+    l, _ := bq.TryReserve()
+    if !l.Deadline().Equal(clk.Now().Add(DefaultVisibilityTimeout)) {
+        t.Fatalf("Deadline = %v: a non-positive timeout must select the default", l.Deadline())
+    }
+    bq.Put(2)
+    clk.Advance(DefaultVisibilityTimeout)
+    if bq.Len() != 1 || bq.InFlight() != 1 {
+        t.Fatalf("Len = %d, InFlight = %d: an expired lease that does not fit must stay leased", bq.Len(), bq.InFlight())
+    }
+    if !l.Deadline().Equal(clk.Now().Add(DefaultVisibilityTimeout)) {
+        t.Fatalf("Deadline = %v: the lease must be extended by the visibility timeout", l.Deadline())
+    }
+}
+
+func TestExpiredLeaseWakesTaker(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithVisibilityTimeout(time.Minute))
+    bq.Put(1)
+    if _, err := bq.Reserve(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    res := make(chan int, 1)
+    go func() {
+        v, err := bq.Take(context.Background())
+        if err != nil {
+            t.Errorf("take: %v", err)
+        }
+        res <- v
+    }()
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Minute)
+    select {
+    case v := <-res:
+        if v != 1 {
+            t.Fatalf("take=%d want 1", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("take not woken when the lease expired")
+    }
+}
+
+func TestCloseWaitsForLeases(t *testing.T) {
+    bq := New[int](false)
+    bq.Put(1)
+    l, _ := bq.TryReserve()
+    bq.Close()
+    errc := make(chan error, 1)
+    go func() {
+        _, err := bq.Take(context.Background())
+        errc <- err
+    }()
+    waitForTakers(t, bq, 1)
+    if err := l.Ack(); err != nil {
+        t.Fatalf("Ack after Close: %v", err)
+    }
+    select {
+    case err := <-errc:
+        if !errors.Is(err, ErrClosed) {
+            t.Fatalf("take = %v want ErrClosed", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("take not woken when the last lease was settled")
+    }
+
+    bq = New[int](false)
+    bq.Put(2)
+    l, _ = bq.TryReserve()
+    bq.CloseNow()
+    if err := l.Nack(); !errors.Is(err, ErrLeaseExpired) {
+        t.Fatalf("Nack after CloseNow = %v want ErrLeaseExpired", err)
+    }
+    if _, err := bq.Reserve(context.Background()); !errors.Is(err, ErrClosed) {
+        t.Fatalf("Reserve after CloseNow = %v want ErrClosed", err)
+    }
+}
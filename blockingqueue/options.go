@@ -0,0 +1,104 @@
+package blockingqueue
+
+import (
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// Option configures a blocking queue at construction time. Pass options to New
+// or NewWithCapacity.
+type Option func(*options)
+
+type options struct {
+    queue []base.Option
+    fair  bool
+    clock Clock
+
+    visibility time.Duration
+
+    maxDeliveries int
+    deadLetter    []base.Option
+    backoff       Backoff
+}
+
+// WithQueueOptions passes options through to the underlying xyqueue.Queue,
+// for example a maximum size and overflow policy:
+//
+//	q := blockingqueue.New[int](false,
+//		blockingqueue.WithQueueOptions(xyqueue.WithMaxSize(100, xyqueue.DropOldest)))
+func WithQueueOptions(opts ...base.Option) Option {
+    return func(o *options) { o.queue = append(o.queue, opts...) }
+}
+
+// WithMaxSize bounds the queue to at most n elements. Producers calling Put,
+// PutMany or their Context variants block while the queue is full; TryPut and
+// OfferTimeout report ErrFull instead. It is shorthand for
+// WithQueueOptions(xyqueue.WithMaxSize(n, xyqueue.Reject)).
+func WithMaxSize(n int) Option {
+    return WithQueueOptions(base.WithMaxSize(n, base.Reject))
+}
+
+// WithFairness serves blocked consumers in strict arrival order: the first
+// goroutine to block in Take is the first to receive an element. New elements
+// are handed directly to the longest-waiting consumer instead of being queued,
+// so a consumer arriving later cannot overtake it. Fairness costs some
+// throughput under heavy contention and is off by default.
+func WithFairness() Option {
+    return func(o *options) { o.fair = true }
+}
+
+// WithClock sets the clock used to schedule deliveries, such as the due times
+// of a DelayQueue, and to expire values given a TTL (see WithQueueOptions).
+// The default is SystemClock; tests inject a fake clock to control time
+// without sleeping.
+func WithClock(c Clock) Option {
+    return func(o *options) { o.clock = c }
+}
+
+// WithVisibilityTimeout sets how long a value reserved with Queue.Reserve
+// stays leased before it returns to the queue for redelivery. The default is
+// DefaultVisibilityTimeout, which d <= 0 also selects.
+func WithVisibilityTimeout(d time.Duration) Option {
+    return func(o *options) {
+        if d <= 0 {
+            d = DefaultVisibilityTimeout
+        }
+        o.visibility = d
+    }
+}
+
+// WithDeadLetter gives up on a value reserved with Queue.Reserve once it has
+// been delivered maxDeliveries times without being acknowledged: instead of
+// returning to the queue after its last failed delivery, it moves to a
+// dead-letter queue (see Queue.DeadLetters) together with the error of that
+// delivery. opts configure the dead-letter queue, for example to bound it:
+//
+//	q := blockingqueue.New[string](true,
+//		blockingqueue.WithDeadLetter(5, xyqueue.WithMaxSize(1000, xyqueue.DropOldest)))
+func WithDeadLetter(maxDeliveries int, opts ...base.Option) Option {
+    return func(o *options) {
+        o.maxDeliveries = maxDeliveries
+        o.deadLetter = opts
+    }
+}
+
+// WithBackoff sets the backoff Queue.Retry and Lease.Retry wait before
+// redelivering a failed value. The default is DefaultBackoff.
+func WithBackoff(p Backoff) Option {
+    return func(o *options) { o.backoff = p }
+}
+
+func collect(opts []Option) options {
+    o := options{clock: SystemClock, visibility: DefaultVisibilityTimeout, backoff: DefaultBackoff}
+    for _, opt := range opts {
+        opt(&o)
+    }
+    if o.clock == nil {
+        o.clock = SystemClock
+    }
+    // TTLs of the underlying queue run on the same clock, unless the caller
+    // passed xyqueue.WithClock.
+    o.queue = append([]base.Option{base.WithClock(o.clock.Now)}, o.queue...)
+    return o
+}
@@ -0,0 +1,221 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "runtime/debug"
+    "sync"
+    "time"
+)
+
+// Pool is a set of worker goroutines taking values from a Queue and passing
+// them to a handler; see Run.
+//
+// All methods are safe for concurrent use by multiple goroutines.
+type Pool[T comparable] struct {
+    q      *Queue[T]
+    ctx    context.Context
+    handle func(ctx context.Context, v T) error
+    o      poolOptions
+
+    wg      sync.WaitGroup
+    mu      sync.Mutex
+    onErr   func(v T, err error)
+    workers map[int]context.CancelFunc // by worker id; cancel stops taking
+    nextID  int
+    stopped bool
+    errs    []error
+}
+
+// PoolOption configures a Pool. Pass options to Run.
+type PoolOption func(*poolOptions)
+
+type poolOptions struct {
+    timeout time.Duration
+    retry   bool
+}
+
+// WithItemTimeout bounds the time the handler may spend on one value: its
+// context is canceled after d. The handler must observe the context; a
+// handler that ignores it is not abandoned.
+func WithItemTimeout(d time.Duration) PoolOption {
+    return func(o *poolOptions) { o.timeout = d }
+}
+
+// WithRetryOnError hands every value whose handler failed or panicked to
+// Queue.Retry, so it is delivered again after the queue's backoff, or
+// dead-lettered (see WithBackoff and WithDeadLetter). The error is reported
+// as well.
+func WithRetryOnError() PoolOption {
+    return func(o *poolOptions) { o.retry = true }
+}
+
+// PanicError is the error reported when a handler panics. The worker
+// recovers and carries on with the next value.
+type PanicError struct {
+    Value any    // the value passed to panic
+    Stack []byte // the stack of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+    return fmt.Sprintf("blockingqueue: handler panicked: %v", e.Value)
+}
+
+// Run starts workers goroutines that take values from q and call handle with
+// each, until the pool is stopped, q is closed and drained, or ctx is done.
+// Each value is reported to q.Done once handled successfully, clearing its
+// failure count, or to q.TaskDone once it failed, so q.Join waits for the
+// pool's work. handle receives ctx, bounded by WithItemTimeout if given.
+//
+// A handler error, or a panic recovered as a *PanicError, does not stop the
+// worker; it is collected and returned, joined, by Wait and Stop, or passed
+// to the callback installed with SetErrorHandler.
+func Run[T comparable](ctx context.Context, q *Queue[T], workers int, handle func(ctx context.Context, v T) error, opts ...PoolOption) *Pool[T] {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    p := &Pool[T]{q: q, ctx: ctx, handle: handle, workers: make(map[int]context.CancelFunc)}
+    for _, opt := range opts {
+        opt(&p.o)
+    }
+    p.Resize(workers)
+    return p
+}
+
+// Resize changes the number of workers to n (n < 0 is treated as 0). Extra
+// workers are started at once; surplus ones stop taking values and exit once
+// their current value, if any, is handled. Resize has no effect once the
+// pool is stopped.
+func (p *Pool[T]) Resize(n int) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.stopped {
+        return
+    }
+    for len(p.workers) < n {
+        wctx, cancel := context.WithCancel(p.ctx)
+        id := p.nextID
+        p.nextID++
+        p.workers[id] = cancel
+        p.wg.Add(1)
+        go p.work(wctx, id)
+    }
+    for id, cancel := range p.workers {
+        if len(p.workers) <= n {
+            break
+        }
+        cancel()
+        delete(p.workers, id)
+    }
+}
+
+// SetErrorHandler calls fn with the value and the error each time the
+// handler fails or panics, instead of collecting the error for Wait and
+// Stop, which suits pools that run for the life of a service; a nil fn
+// collects errors again. fn is called from the worker goroutine. Errors
+// reported before the call are collected, so to handle every error, Run the
+// pool with no workers, install fn and then Resize it.
+func (p *Pool[T]) SetErrorHandler(fn func(v T, err error)) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.onErr = fn
+}
+
+// Size returns the number of running workers.
+func (p *Pool[T]) Size() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return len(p.workers)
+}
+
+// Stop stops the workers from taking more values and waits until the
+// handlers in progress return or ctx is done. Values still queued stay in
+// the queue. Returns ctx.Err() if ctx ended first, otherwise what Wait
+// returns.
+func (p *Pool[T]) Stop(ctx context.Context) error {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    p.mu.Lock()
+    p.stopped = true
+    for id, cancel := range p.workers {
+        cancel()
+        delete(p.workers, id)
+    }
+    p.mu.Unlock()
+
+    done := make(chan error, 1)
+    go func() { done <- p.Wait() }()
+    select {
+    case err := <-done:
+        return err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Wait blocks until every worker has exited, because the pool was stopped
+// or resized to zero, q was closed and drained, or the context given to Run
+// is done. It returns the handler errors collected so far, joined with
+// errors.Join, or nil.
+func (p *Pool[T]) Wait() error {
+    p.wg.Wait()
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return errors.Join(p.errs...)
+}
+
+// work is the loop of one worker.
+func (p *Pool[T]) work(ctx context.Context, id int) {
+    defer p.wg.Done()
+    defer func() {
+        p.mu.Lock()
+        delete(p.workers, id)
+        p.mu.Unlock()
+    }()
+    for ctx.Err() == nil { // Take would still return a queued value
+        v, err := p.q.Take(ctx)
+        if err != nil {
+            return
+        }
+        if err := p.run(v); err != nil {
+            p.fail(v, err)
+            p.q.TaskDone()
+            continue
+        }
+        p.q.Done(v)
+    }
+}
+
+// run calls the handler for v, turning a panic into a *PanicError.
+func (p *Pool[T]) run(v T) (err error) {
+    ctx := p.ctx
+    if p.o.timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, p.o.timeout)
+        defer cancel()
+    }
+    defer func() {
+        if r := recover(); r != nil {
+            err = &PanicError{Value: r, Stack: debug.Stack()}
+        }
+    }()
+    return p.handle(ctx, v)
+}
+
+// fail reports that handling v failed with err.
+func (p *Pool[T]) fail(v T, err error) {
+    if p.o.retry {
+        p.q.Retry(v, err)
+    }
+    p.mu.Lock()
+    onErr := p.onErr
+    if onErr == nil {
+        p.errs = append(p.errs, err)
+    }
+    p.mu.Unlock()
+    if onErr != nil {
+        onErr(v, err)
+    }
+}
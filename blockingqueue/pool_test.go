@@ -0,0 +1,146 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestPoolProcessesAndAggregates(t *testing.T) {
+    bq := New[int](false)
+    for i := 1; i <= 10; i++ {
+        bq.Put(i)
+    }
+    bq.Close()
+    errBad := errors.New("bad")
+    var handled atomic.Int32
+    p := Run(context.Background(), bq, 3, func(ctx context.Context, v int) error {
+        handled.Add(1)
+        switch v {
+        case 3:
+            return errBad
+        case 5:
+            panic("boom")
+        }
+        return nil
+    })
+    err := p.Wait()
+    if handled.Load() != 10 || bq.Unfinished() != 0 {
+        t.Fatalf("handled %d values, %d unfinished", handled.Load(), bq.Unfinished())
+    }
+    var pe *PanicError
+    if !errors.Is(err, errBad) || !errors.As(err, &pe) || pe.Value != "boom" || len(pe.Stack) == 0 {
+        t.Fatalf("Wait = %v want the handler error and the recovered panic", err)
+    }
+    if p.Size() != 0 {
+        t.Fatalf("Size = %d after the queue was drained", p.Size())
+    }
+}
+
+func TestPoolResizeAndStop(t *testing.T) {
+    bq := New[int](false)
+    started, release := make(chan int, 4), make(chan struct{})
+    p := Run(context.Background(), bq, 1, func(ctx context.Context, v int) error {
+        started <- v
+        <-release
+        return nil
+    })
+    p.Resize(3)
+    if p.Size() != 3 {
+        t.Fatalf("Size = %d want 3", p.Size())
+    }
+    bq.PutMany(1, 2, 3, 4)
+    for range 3 {
+        <-started
+    }
+    p.Resize(2)
+    if p.Size() != 2 {
+        t.Fatalf("Size = %d want 2", p.Size())
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if err := p.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Stop with busy handlers = %v want DeadlineExceeded", err)
+    }
+    close(release)
+    if err := p.Stop(context.Background()); err != nil {
+        t.Fatalf("Stop: %v", err)
+    }
+    if bq.Len() != 1 || p.Size() != 0 {
+        t.Fatalf("Len = %d Size = %d: stopping must leave queued values alone", bq.Len(), p.Size())
+    }
+    p.Resize(1)
+    if p.Size() != 0 {
+        t.Fatal("Resize must have no effect on a stopped pool")
+    }
+}
+
+func TestPoolItemTimeoutAndRetry(t *testing.T) {
+    bq := New[string](true, WithBackoff(Backoff{Initial: time.Hour}))
+    failed := make(chan error, 1)
+    p := Run(context.Background(), bq, 0, func(ctx context.Context, v string) error {
+        <-ctx.Done()
+        return ctx.Err()
+    }, WithItemTimeout(10*time.Millisecond), WithRetryOnError())
+    p.SetErrorHandler(func(v string, err error) { failed <- err })
+    p.Resize(1)
+    defer p.Stop(context.Background())
+    bq.Put("slow")
+    select {
+    case err := <-failed:
+        if !errors.Is(err, context.DeadlineExceeded) {
+            t.Fatalf("reported %v want DeadlineExceeded", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("item timeout not applied")
+    }
+    if bq.Retrying() != 1 {
+        t.Fatal("the failed value must be scheduled for a retry")
+    }
+}
+
+func TestPoolForgetsFailuresOnSuccess(t *testing.T) {
+    bq := New[string](true, WithBackoff(Backoff{Initial: time.Millisecond}))
+    var calls atomic.Int32
+    p := Run(context.Background(), bq, 1, func(context.Context, string) error {
+        if calls.Add(1) == 1 {
+            return errors.New("boom")
+        }
+        return nil
+    }, WithRetryOnError())
+    defer p.Stop(context.Background())
+    bq.Put("a")
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    if err := bq.Join(ctx); err != nil {
+        t.Fatalf("Join: %v", err)
+    }
+    bq.mu.Lock()
+    n := len(bq.attempts)
+    bq.mu.Unlock()
+    if calls.Load() != 2 || n != 0 {
+        t.Fatalf("calls = %d, attempts = %d: a value handled after a retry must have its failures forgotten", calls.Load(), n)
+    }
+}
+
+func TestPoolSetErrorHandler(t *testing.T) {
+    bq := New[string](false)
+    boom := errors.New("boom")
+    p := Run(context.Background(), bq, 1, func(context.Context, string) error { return boom })
+    failed := make(chan string, 1)
+    p.SetErrorHandler(func(v string, err error) { failed <- v })
+    bq.Put("a")
+    select {
+    case v := <-failed:
+        if v != "a" {
+            t.Fatalf("handler got %q want a", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("the error handler set with SetErrorHandler was not called")
+    }
+    if err := p.Stop(context.Background()); err != nil {
+        t.Fatalf("Stop = %v; errors passed to the handler must not be collected", err)
+    }
+}
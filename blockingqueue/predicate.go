@@ -0,0 +1,67 @@
+package blockingqueue
+
+import "context"
+
+// TakeIf blocks until some queued value satisfies pred, then removes and
+// returns the first such value in FIFO order; values before it stay queued.
+// Unlike xyqueue.Queue.DequeueIf it is not limited to the head, since a
+// head that does not match could block the caller forever. Errors are those
+// of Take: after Close it returns ErrClosed once no remaining value matches.
+//
+// pred is called with the queue's lock held, once per queued value each time
+// the caller checks, and must not call methods on the queue. Every Put wakes
+// all blocked TakeIf callers to re-check, so the approach suits a handful of
+// selective consumers rather than many.
+func (b *Queue[T]) TakeIf(ctx context.Context, pred func(v T) bool) (T, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for {
+        b.reclaim()
+        if v, ok := b.q.FindFunc(pred); ok {
+            b.q.Remove(v) // the first occurrence of v is the first match
+            b.taken++
+            b.putters.wake(1)
+            return v, nil
+        }
+        var zero T
+        if b.drained() {
+            return zero, ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return zero, err
+        }
+        b.wait(ctx, &b.matchers)
+    }
+}
+
+// RemoveFunc deletes every queued value for which pred returns true, returns
+// the number deleted and wakes as many blocked producers. pred is called with
+// the queue's lock held and must not call methods on the queue.
+func (b *Queue[T]) RemoveFunc(pred func(v T) bool) int {
+    b.mu.Lock()
+    n := b.q.RemoveFunc(pred)
+    b.putters.wake(n)
+    if n > 0 {
+        b.checkJoin()
+    }
+    b.mu.Unlock()
+    return n
+}
+
+// RetainFunc deletes every queued value for which keep returns false; it is
+// RemoveFunc with the predicate negated.
+func (b *Queue[T]) RetainFunc(keep func(v T) bool) int {
+    return b.RemoveFunc(func(v T) bool { return !keep(v) })
+}
+
+// FindFunc returns the first queued value, in FIFO order, for which pred
+// returns true, without removing it.
+func (b *Queue[T]) FindFunc(pred func(v T) bool) (T, bool) {
+    b.mu.Lock()
+    v, ok := b.q.FindFunc(pred)
+    b.mu.Unlock()
+    return v, ok
+}
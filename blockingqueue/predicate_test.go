@@ -0,0 +1,124 @@
+package blockingqueue
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func isEven(v int) bool { return v%2 == 0 }
+
+func waitForMatchers[T comparable](t *testing.T, bq *Queue[T], n int) {
+    t.Helper()
+    deadline := time.Now().Add(time.Second)
+    for {
+        bq.mu.Lock()
+        parked := bq.matchers.n
+        bq.mu.Unlock()
+        if parked >= n {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("only %d of %d TakeIf callers parked", parked, n)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestTakeIfSkipsNonMatching(t *testing.T) {
+    bq := New[int](false)
+    bq.PutMany(1, 3, 4, 5)
+    v, err := bq.TakeIf(context.Background(), isEven)
+    if err != nil || v != 4 {
+        t.Fatalf("TakeIf = %d,%v want 4,nil", v, err)
+    }
+    if got := bq.q.ToSlice(); len(got) != 3 || got[0] != 1 || got[2] != 5 {
+        t.Fatalf("remaining = %v want [1 3 5]", got)
+    }
+}
+
+func TestTakeIfWaitsForMatch(t *testing.T) {
+    bq := New[int](false)
+    got := make(chan int, 1)
+    go func() {
+        v, err := bq.TakeIf(context.Background(), isEven)
+        if err != nil {
+            t.Error(err)
+        }
+        got <- v
+    }()
+    waitForMatchers(t, bq, 1)
+
+    // A plain consumer must still receive the odd value: the blocked TakeIf
+    // caller is woken too but must not swallow the wake-up meant for Take.
+    taken := make(chan int, 1)
+    go func() {
+        v, _ := bq.Take(context.Background())
+        taken <- v
+    }()
+    waitForTakers(t, bq, 1)
+    bq.Put(1)
+    if v := <-taken; v != 1 {
+        t.Fatalf("Take = %d want 1", v)
+    }
+    select {
+    case v := <-got:
+        t.Fatalf("TakeIf returned %d before a match was put", v)
+    case <-time.After(10 * time.Millisecond):
+    }
+    bq.Put(2)
+    select {
+    case v := <-got:
+        if v != 2 {
+            t.Fatalf("TakeIf = %d want 2", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("TakeIf not woken by matching put")
+    }
+}
+
+func TestTakeIfCloseAndCancel(t *testing.T) {
+    bq := New[int](false)
+    bq.Put(1)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    if _, err := bq.TakeIf(ctx, isEven); err != context.DeadlineExceeded {
+        t.Fatalf("err = %v want DeadlineExceeded", err)
+    }
+
+    errc := make(chan error, 1)
+    go func() {
+        _, err := bq.TakeIf(context.Background(), isEven)
+        errc <- err
+    }()
+    waitForMatchers(t, bq, 1)
+    bq.Close()
+    if err := <-errc; err != ErrClosed {
+        t.Fatalf("err = %v want ErrClosed", err)
+    }
+    if v, err := bq.TakeIf(context.Background(), func(int) bool { return true }); err != nil || v != 1 {
+        t.Fatalf("after Close TakeIf = %d,%v want the remaining 1", v, err)
+    }
+}
+
+func TestRemoveFuncWakesPutters(t *testing.T) {
+    bq := New[int](false, WithMaxSize(3))
+    bq.PutMany(1, 2, 3)
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        bq.PutMany(5, 7)
+    }()
+    time.Sleep(10 * time.Millisecond)
+    if n := bq.RetainFunc(isEven); n != 2 {
+        t.Fatalf("RetainFunc = %d want 2", n)
+    }
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("blocked producer not woken by RemoveFunc")
+    }
+    if v, ok := bq.FindFunc(func(v int) bool { return v > 4 }); !ok || v != 5 {
+        t.Fatalf("FindFunc = %d,%v want 5,true", v, ok)
+    }
+}
@@ -0,0 +1,160 @@
+package blockingqueue
+
+import (
+    "context"
+    "sync"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// PriorityQueue is the blocking counterpart of xyqueue.PriorityQueue: Take
+// waits for the value with the smallest priority. De-duplication, ordering
+// and Close semantics match xyqueue.PriorityQueue and Queue respectively.
+//
+// All methods are safe for concurrent use by multiple goroutines.
+type PriorityQueue[T comparable] struct {
+    mu     sync.Mutex
+    takers waitList[T]
+    q      *base.PriorityQueue[T]
+    closed bool
+}
+
+// NewPriorityQueue creates a blocking priority queue ordered by the priority
+// passed to PutPriority.
+func NewPriorityQueue[T comparable](dedup bool) *PriorityQueue[T] {
+    return &PriorityQueue[T]{q: base.NewPriorityQueue[T](dedup)}
+}
+
+// NewPriorityQueueFunc creates a blocking priority queue that orders values
+// with equal priority by less; see xyqueue.NewPriorityQueueFunc.
+func NewPriorityQueueFunc[T comparable](dedup bool, less func(a, b T) bool) *PriorityQueue[T] {
+    return &PriorityQueue[T]{q: base.NewPriorityQueueFunc[T](dedup, less)}
+}
+
+// Put adds v with priority 0. See PutPriority.
+func (b *PriorityQueue[T]) Put(v T) bool {
+    return b.PutPriority(v, 0)
+}
+
+// PutPriority adds v with the given priority; smaller priorities are taken
+// first. Returns true if the value was added, or false when de-duplication is
+// enabled and v is already present, or when the queue is closed. Wakes one
+// waiting consumer when an element is added.
+func (b *PriorityQueue[T]) PutPriority(v T, priority int64) bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.closed || !b.q.EnqueuePriority(v, priority) {
+        return false
+    }
+    b.takers.wake(1)
+    return true
+}
+
+// UpdatePriority changes the priority of v if it is present and reports
+// whether it was.
+func (b *PriorityQueue[T]) UpdatePriority(v T, priority int64) bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.q.UpdatePriority(v, priority)
+}
+
+// TryTake removes and returns the value with the smallest priority without
+// blocking. ok is false if the queue is empty.
+func (b *PriorityQueue[T]) TryTake() (v T, ok bool) {
+    b.mu.Lock()
+    v, ok = b.q.Dequeue()
+    b.mu.Unlock()
+    return
+}
+
+// Take blocks until an element is available or ctx is done and returns the
+// value with the smallest priority. Errors are as for Queue.Take: ctx.Err()
+// on cancellation and ErrClosed once a closed queue is drained.
+func (b *PriorityQueue[T]) Take(ctx context.Context) (T, error) {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for {
+        if v, ok := b.q.Dequeue(); ok {
+            return v, nil
+        }
+        var zero T
+        if b.closed {
+            return zero, ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return zero, err
+        }
+        park(ctx, &b.mu, &b.takers)
+    }
+}
+
+// Peek returns the value with the smallest priority without removing it.
+// ok is false when empty.
+func (b *PriorityQueue[T]) Peek() (v T, ok bool) {
+    b.mu.Lock()
+    v, ok = b.q.Peek()
+    b.mu.Unlock()
+    return
+}
+
+// Len returns the number of elements currently queued.
+func (b *PriorityQueue[T]) Len() int {
+    b.mu.Lock()
+    n := b.q.Len()
+    b.mu.Unlock()
+    return n
+}
+
+// IsEmpty reports whether the queue is empty.
+func (b *PriorityQueue[T]) IsEmpty() bool { return b.Len() == 0 }
+
+// Contains reports whether v is currently present in the queue.
+func (b *PriorityQueue[T]) Contains(v T) bool {
+    b.mu.Lock()
+    ok := b.q.Contains(v)
+    b.mu.Unlock()
+    return ok
+}
+
+// Remove deletes v from the queue if present. Returns true if removed.
+func (b *PriorityQueue[T]) Remove(v T) bool {
+    b.mu.Lock()
+    removed := b.q.Remove(v)
+    b.mu.Unlock()
+    return removed
+}
+
+// Clear removes all elements from the queue.
+func (b *PriorityQueue[T]) Clear() {
+    b.mu.Lock()
+    b.q.Clear()
+    b.mu.Unlock()
+}
+
+// Close marks the queue closed; see Queue.Close.
+func (b *PriorityQueue[T]) Close() {
+    b.mu.Lock()
+    b.closed = true
+    b.takers.wakeAll()
+    b.mu.Unlock()
+}
+
+// CloseNow closes the queue and discards every queued element.
+func (b *PriorityQueue[T]) CloseNow() {
+    b.mu.Lock()
+    b.closed = true
+    b.q.Clear()
+    b.takers.wakeAll()
+    b.mu.Unlock()
+}
+
+// IsClosed reports whether Close or CloseNow has been called.
+func (b *PriorityQueue[T]) IsClosed() bool {
+    b.mu.Lock()
+    closed := b.closed
+    b.mu.Unlock()
+    return closed
+}
@@ -0,0 +1,58 @@
+package blockingqueue
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestPriorityTakeOrder(t *testing.T) {
+    pq := NewPriorityQueue[string](true)
+    pq.PutPriority("low", 10)
+    pq.PutPriority("high", 1)
+    if pq.PutPriority("high", 5) {
+        t.Fatal("expected duplicate to be ignored")
+    }
+    pq.UpdatePriority("low", 0)
+    ctx := context.Background()
+    if v, _ := pq.Take(ctx); v != "low" {
+        t.Fatalf("take=%q want low after UpdatePriority", v)
+    }
+    if v, _ := pq.Take(ctx); v != "high" {
+        t.Fatalf("take=%q want high", v)
+    }
+}
+
+func TestPriorityTakeBlocksAndCloses(t *testing.T) {
+    pq := NewPriorityQueue[int](false)
+    res := make(chan int, 1)
+    go func() {
+        v, err := pq.Take(context.Background())
+        if err != nil {
+            t.Errorf("take: %v", err)
+        }
+        res <- v
+    }()
+    time.Sleep(5 * time.Millisecond)
+    pq.PutPriority(42, 3)
+    select {
+    case v := <-res:
+        if v != 42 {
+            t.Fatalf("take=%d want 42", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("take not woken by put")
+    }
+
+    pq.Put(1)
+    pq.Close()
+    if pq.Put(2) {
+        t.Fatal("put after close must fail")
+    }
+    if v, err := pq.Take(context.Background()); err != nil || v != 1 {
+        t.Fatalf("take got (%d,%v) want (1,nil)", v, err)
+    }
+    if _, err := pq.Take(context.Background()); !IsClosedError(err) {
+        t.Fatalf("take err=%v want ErrClosed", err)
+    }
+}
@@ -0,0 +1,169 @@
+package blockingqueue
+
+import (
+    "container/heap"
+    "math"
+    "math/rand/v2"
+    "time"
+
+    base "github.com/xyhelper/xyqueue"
+)
+
+// Backoff describes capped exponential backoff with jitter. The delay before
+// the retry that follows the n-th failed delivery is Initial*Multiplier^(n-1),
+// capped at Max, and then shortened by a random fraction of up to Jitter of
+// itself, so that values failing together do not all come back at once.
+type Backoff struct {
+    Initial    time.Duration // delay after the first failure
+    Max        time.Duration // upper bound on the delay; 0 means none
+    Multiplier float64       // growth per failure; 0 means 2
+    Jitter     float64       // in [0, 1]; 0 disables jitter
+}
+
+// DefaultBackoff is the backoff of queues created without WithBackoff.
+var DefaultBackoff = Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+// Delay returns the delay before redelivering a value after its n-th failed
+// delivery (n >= 1).
+func (p Backoff) Delay(n int) time.Duration {
+    m := p.Multiplier
+    if m == 0 {
+        m = 2
+    }
+    d := float64(p.Initial) * math.Pow(m, float64(max(n, 1)-1))
+    if p.Max > 0 && d > float64(p.Max) {
+        d = float64(p.Max)
+    }
+    if j := min(max(p.Jitter, 0), 1); j > 0 {
+        d -= d * j * rand.Float64()
+    }
+    if d >= math.MaxInt64 {
+        return math.MaxInt64
+    }
+    return time.Duration(d)
+}
+
+// Retry reports that processing v, a value taken from the queue, failed with
+// err, and schedules v to be delivered again, at the tail of the queue, once
+// the queue's backoff (see WithBackoff) has passed. Failures are counted per
+// value until it is acknowledged (see Lease.Ack and Forget) or dead-lettered,
+// and the delay grows with each one. If the queue was created with
+// WithDeadLetter and v has now failed as often as allowed, it moves to the
+// dead-letter queue instead.
+//
+// Returns (true, nil) when v was scheduled, (false, nil) when it was
+// dead-lettered or de-duplication found it already present, and
+// (false, ErrClosed) on a closed queue, in which case the caller still owns
+// v. While awaiting its retry v counts as present for de-duplication.
+func (b *Queue[T]) Retry(v T, err error) (bool, error) {
+    return b.RetryWith(v, err, b.backoff)
+}
+
+// RetryWith is Retry with the backoff p instead of the queue's.
+func (b *Queue[T]) RetryWith(v T, err error, p Backoff) (bool, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.closed {
+        return false, ErrClosed
+    }
+    b.reclaim()
+    if b.isOutside(v) || b.dedup && b.q.Contains(v) {
+        return false, nil
+    }
+    return b.schedule(v, b.attempts[v]+1, err, p), nil
+}
+
+// Retry settles the lease as a failed delivery like Fail, except that the
+// value comes back to the tail of the queue only once the queue's backoff
+// has passed (see Queue.Retry). Returns ErrLeaseExpired if the lease is no
+// longer held.
+func (l *Lease[T]) Retry(err error) error {
+    b := l.b
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if !b.held(l) {
+        return ErrLeaseExpired
+    }
+    b.release(l)
+    b.schedule(l.v, l.attempt, err, b.backoff)
+    return nil
+}
+
+// Retrying returns the number of values waiting for their backoff to pass
+// before they are delivered again.
+func (b *Queue[T]) Retrying() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.reclaim()
+    return len(b.retries)
+}
+
+// Forget reports that v, a value taken with Take after failing before, has
+// now been processed successfully: the failures counted for it by Retry are
+// dropped, so that it starts afresh if it is put again. Leased values are
+// forgotten by Lease.Ack instead.
+func (b *Queue[T]) Forget(v T) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    delete(b.attempts, v)
+}
+
+// schedule arranges for v, which has failed its n-th delivery with err, to
+// be delivered again after the backoff p, or dead-letters it. It reports
+// whether v was scheduled. b.mu must be held.
+func (b *Queue[T]) schedule(v T, n int, err error, p Backoff) bool {
+    if b.exhausted(n) {
+        b.bury(v, n, err)
+        b.checkDrained()
+        b.checkJoin()
+        return false
+    }
+    b.attempts[v] = n
+    heap.Push(&b.retries, retry[T]{v: v, due: b.clock.Now().Add(p.Delay(n))})
+    b.setOutside(v, true)
+    if b.retries[0].v == v {
+        b.rearm()
+    }
+    return true
+}
+
+// promote moves the retries that are due at now to the tail of the queue,
+// stopping when a bounded queue is full. b.mu must be held.
+func (b *Queue[T]) promote(now time.Time) {
+    for len(b.retries) > 0 && !b.retries[0].due.After(now) {
+        v := b.retries[0].v
+        b.setOutside(v, false)
+        if b.offer(v) == base.Full {
+            b.setOutside(v, true)
+            return
+        }
+        heap.Pop(&b.retries)
+    }
+    b.checkDrained()
+}
+
+// retry is a value awaiting redelivery.
+type retry[T comparable] struct {
+    v   T
+    due time.Time
+}
+
+// retryHeap orders pending retries by due time; it implements
+// heap.Interface.
+type retryHeap[T comparable] []retry[T]
+
+func (h retryHeap[T]) Len() int { return len(h) }
+
+func (h retryHeap[T]) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+
+func (h retryHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *retryHeap[T]) Push(x any) { *h = append(*h, x.(retry[T])) }
+
+func (h *retryHeap[T]) Pop() any {
+    old := *h
+    r := old[len(old)-1]
+    old[len(old)-1] = retry[T]{}
+    *h = old[:len(old)-1]
+    return r
+}
@@ -0,0 +1,174 @@
+package blockingqueue
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+    p := Backoff{Initial: time.Second, Max: 10 * time.Second}
+    for n, want := range map[int]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 10 * time.Second, 500: 10 * time.Second} {
+        if got := p.Delay(n); got != want {
+            t.Fatalf("Delay(%d) = %v want %v", n, got, want)
+        }
+    }
+    p.Jitter = 0.5
+    for range 100 {
+        if d := p.Delay(2); d < time.Second || d > 2*time.Second {
+            t.Fatalf("jittered Delay(2) = %v out of [1s, 2s]", d)
+        }
+    }
+    if d := (Backoff{Initial: time.Hour, Multiplier: 10}).Delay(100); d <= 0 {
+        t.Fatalf("uncapped Delay overflowed to %v", d)
+    }
+}
+
+func TestRetryAfterBackoff(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[string](true, WithClock(clk), WithBackoff(Backoff{Initial: time.Second}))
+    bq.PutMany("a", "b")
+    v, _ := bq.TryTake()
+    if ok, err := bq.Retry(v, errors.New("boom")); !ok || err != nil {
+        t.Fatalf("Retry = %v,%v", ok, err)
+    }
+    if bq.Retrying() != 1 || bq.Put("a") {
+        t.Fatal("a value awaiting its retry must count as present for dedup")
+    }
+    if ok, _ := bq.Retry("a", nil); ok {
+        t.Fatal("a value already awaiting its retry must not be scheduled twice")
+    }
+    clk.Advance(999 * time.Millisecond)
+    bq.TryTake() // b
+    if _, ok := bq.TryTake(); ok {
+        t.Fatal("a must not come back before its backoff passed")
+    }
+    clk.Advance(time.Millisecond)
+    if v, ok := bq.TryTake(); !ok || v != "a" {
+        t.Fatalf("TryTake = %q,%v want a after the backoff", v, ok)
+    }
+    bq.Retry("a", nil)
+    clk.Advance(time.Second)
+    if bq.Len() != 0 {
+        t.Fatal("the second retry must wait twice as long")
+    }
+    clk.Advance(time.Second)
+    v, _ = bq.TryTake()
+    bq.RetryWith(v, nil, Backoff{Initial: time.Millisecond, Max: time.Millisecond})
+    clk.Advance(time.Millisecond)
+    if bq.Len() != 1 {
+        t.Fatal("RetryWith must use the given backoff")
+    }
+}
+
+func TestForgetResetsAttempts(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[string](true, WithClock(clk), WithBackoff(Backoff{Initial: time.Second}))
+    bq.Put("a")
+    v, _ := bq.TryTake()
+    bq.Retry(v, nil)
+    clk.Advance(time.Second)
+    v, _ = bq.TryTake()
+    bq.Forget(v)
+
+    bq.Put("a")
+    v, _ = bq.TryTake()
+    bq.Retry(v, nil)
+    clk.Advance(time.Second)
+    if bq.Len() != 1 {
+        t.Fatal("after Forget the next failure must count as the first")
+    }
+}
+
+func TestRetryWakesTaker(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithBackoff(Backoff{Initial: time.Minute}))
+    res := make(chan int, 1)
+    go func() {
+        v, err := bq.Take(context.Background())
+        if err != nil {
+            t.Errorf("take: %v", err)
+        }
+        res <- v
+    }()
+    waitForTakers(t, bq, 1)
+    bq.Retry(5, nil)
+    waitForTimers(t, clk, 1)
+    clk.Advance(time.Minute)
+    select {
+    case v := <-res:
+        if v != 5 {
+            t.Fatalf("take=%d want 5", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("take not woken when the retry fell due")
+    }
+}
+
+func TestRetryDeadLetters(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithBackoff(Backoff{Initial: time.Second}), WithDeadLetter(3))
+    bq.Put(1)
+    l, _ := bq.TryReserve()
+    if err := l.Retry(errors.New("first")); err != nil {
+        t.Fatalf("Lease.Retry: %v", err)
+    }
+    if bq.InFlight() != 0 || bq.Retrying() != 1 {
+        t.Fatal("Lease.Retry must settle the lease and schedule the value")
+    }
+    clk.Advance(time.Second)
+    l, _ = bq.TryReserve()
+    if l.Attempt() != 2 {
+        t.Fatalf("Attempt = %d want 2", l.Attempt())
+    }
+    l.Ack()
+    bq.Retry(1, nil) // a Take-based failure, counted from scratch after Ack
+    clk.Advance(time.Second)
+    v, _ := bq.TryTake()
+    bq.Retry(v, nil)
+    clk.Advance(2 * time.Second)
+    v, _ = bq.TryTake()
+    last := errors.New("last")
+    if ok, err := bq.Retry(v, last); ok || err != nil {
+        t.Fatalf("third failure: Retry = %v,%v want dead-lettered", ok, err)
+    }
+    if d, _ := bq.DeadLetters().Peek(); d.Err != last || d.Attempts != 3 || bq.Retrying() != 0 {
+        t.Fatalf("dead letter = %+v", d)
+    }
+}
+
+func TestCloseWaitsForRetries(t *testing.T) {
+    clk := newFakeClock()
+    bq := New[int](false, WithClock(clk), WithBackoff(Backoff{Initial: time.Second}))
+    bq.Retry(1, nil)
+    bq.Close()
+    if _, err := bq.Retry(2, nil); !errors.Is(err, ErrClosed) {
+        t.Fatalf("Retry on closed queue = %v want ErrClosed", err)
+    }
+    errs := make(chan error, 2)
+    for range 2 {
+        go func() {
+            _, err := bq.Take(context.Background())
+            errs <- err
+        }()
+    }
+    waitForTakers(t, bq, 2)
+    clk.Advance(time.Second)
+    var closed int
+    for range 2 {
+        select {
+        case err := <-errs:
+            if errors.Is(err, ErrClosed) {
+                closed++
+            } else if err != nil {
+                t.Fatalf("take: %v", err)
+            }
+        case <-time.After(time.Second):
+            t.Fatal("takers not released once the last retry was delivered")
+        }
+    }
+    if closed != 1 {
+        t.Fatalf("%d takers got ErrClosed want 1", closed)
+    }
+}
@@ -0,0 +1,155 @@
+package blockingqueue
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// waiter is a goroutine parked until it is woken or its context is done.
+//
+// All fields other than ready are guarded by the mutex of the queue the waiter
+// is parked on. A waker pops the waiter off its list before sending on ready,
+// so each parking receives at most one token and the send never blocks. A
+// waker may also hand the waiter a value directly (see handoff). TakeAny
+// links one shared waiter into each of several queues, all sharing one ready
+// channel, so wake sends without blocking in case the channel already holds
+// a token.
+type waiter[T any] struct {
+    ready      chan struct{}
+    prev, next *waiter[T]
+    list       *waitList[T] // nil when not linked
+    v          T
+    ok         bool // v was handed over
+    shared     bool // ready is shared with waiters on other lists (TakeAny)
+}
+
+// waitList is an intrusive FIFO of parked waiters with O(1) removal, so a
+// waiter that gives up can unlink itself without a scan. Waiters (and their
+// channels) are recycled through pool, so steady-state parking does not
+// allocate.
+type waitList[T any] struct {
+    head, tail *waiter[T]
+    n          int
+    pool       sync.Pool
+}
+
+func (l *waitList[T]) push(w *waiter[T]) {
+    w.list = l
+    w.prev = l.tail
+    w.next = nil
+    if l.tail != nil {
+        l.tail.next = w
+    } else {
+        l.head = w
+    }
+    l.tail = w
+    l.n++
+}
+
+// remove unlinks w and reports whether it was still on the list.
+func (l *waitList[T]) remove(w *waiter[T]) bool {
+    if w.list != l {
+        return false
+    }
+    if w.prev != nil {
+        w.prev.next = w.next
+    } else {
+        l.head = w.next
+    }
+    if w.next != nil {
+        w.next.prev = w.prev
+    } else {
+        l.tail = w.prev
+    }
+    w.prev, w.next, w.list = nil, nil, nil
+    l.n--
+    return true
+}
+
+// pop unlinks and returns the longest-waiting waiter, or nil.
+func (l *waitList[T]) pop() *waiter[T] {
+    w := l.head
+    if w != nil {
+        l.remove(w)
+    }
+    return w
+}
+
+// wake wakes up to n waiters in arrival order. A shared waiter whose channel
+// already holds a token, because another queue woke it first, is unlinked
+// without counting, so the wake-up goes to the next waiter instead.
+func (l *waitList[T]) wake(n int) {
+    for n > 0 {
+        w := l.pop()
+        if w == nil {
+            return
+        }
+        select {
+        case w.ready <- struct{}{}:
+            n--
+        default:
+        }
+    }
+}
+
+// wakeAll wakes every waiter.
+func (l *waitList[T]) wakeAll() { l.wake(l.n) }
+
+// handoff gives v to the longest-waiting waiter and wakes it. Shared waiters
+// are passed over, since they cannot take a value from every queue at once.
+// It reports false, leaving v with the caller, when nobody else is waiting.
+func (l *waitList[T]) handoff(v T) bool {
+    w := l.head
+    for w != nil && w.shared {
+        w = w.next
+    }
+    if w == nil {
+        return false
+    }
+    l.remove(w)
+    w.v, w.ok = v, true
+    w.ready <- struct{}{}
+    return true
+}
+
+// park queues the caller on l, releases mu and blocks until a waker pops it
+// or ctx is done, then re-acquires mu. If a value was handed over it is
+// returned with ok true, even when ctx also ended, so handed values are never
+// lost. Otherwise the caller must re-check its condition, since another
+// goroutine may have acted first.
+//
+// No goroutine is started.
+func park[T any](ctx context.Context, mu *sync.Mutex, l *waitList[T]) (v T, ok bool) {
+    return parkUntil(ctx, mu, l, nil)
+}
+
+// parkUntil is park with an extra wake-up source: it also returns when alarm
+// delivers (a nil alarm never does), as when a scheduled item falls due.
+func parkUntil[T any](ctx context.Context, mu *sync.Mutex, l *waitList[T], alarm <-chan time.Time) (v T, ok bool) {
+    w, _ := l.pool.Get().(*waiter[T])
+    if w == nil {
+        w = &waiter[T]{ready: make(chan struct{}, 1)}
+    }
+    l.push(w)
+    mu.Unlock()
+    select {
+    case <-w.ready:
+    case <-ctx.Done():
+    case <-alarm:
+    }
+    mu.Lock()
+    if !l.remove(w) {
+        // Popped by a waker. Its token may still be buffered if ctx won
+        // the select; drain it so the waiter is clean for reuse.
+        select {
+        case <-w.ready:
+        default:
+        }
+        var zero T
+        v, ok = w.v, w.ok
+        w.v, w.ok = zero, false
+    }
+    l.pool.Put(w)
+    return v, ok
+}
@@ -0,0 +1,106 @@
+package xyqueue
+
+// PushFront inserts v at the head, so it is dequeued next, for urgent work or
+// to undo a Dequeue. Returns true if v was added, under the same rules as
+// Enqueue: de-duplication applies (with the MoveToBack policy an equal queued
+// value is moved to the head instead) and on a full queue the overflow policy
+// decides. v gets the queue's default TTL. Use OfferFront to learn why v was
+// not added. Amortized complexity: O(1).
+func (q *Queue[T]) PushFront(v T) bool {
+	return q.core.PushFront(v)
+}
+
+// OfferFront is PushFront reporting the outcome as Offer does.
+func (q *Queue[T]) OfferFront(v T) Result {
+	return q.core.OfferFront(v)
+}
+
+// PopBack removes and returns the tail value, the most recently enqueued one.
+// The second result is false when the queue is empty. Amortized complexity:
+// O(1).
+func (q *Queue[T]) PopBack() (T, bool) {
+	return q.core.PopBack()
+}
+
+// PeekBack returns the tail value without removing it.
+// The second result is false when the queue is empty. Complexity: O(1).
+func (q *Queue[T]) PeekBack() (T, bool) {
+	return q.core.PeekBack()
+}
+
+// PeekAt returns the i-th value counted from the head (PeekAt(0) is Peek)
+// without removing it. The second result is false when i is out of range.
+// Complexity: O(1), or O(i) while values removed from the middle of the queue
+// still await compaction.
+func (q *Queue[T]) PeekAt(i int) (T, bool) {
+	return q.core.PeekAt(i)
+}
+
+// PushFront inserts v at the head, so it is dequeued next, for urgent work or
+// to undo a Dequeue. Returns true if v was added, under the same rules as
+// Enqueue: de-duplication applies (with the MoveToBack policy a queued value
+// with the same key is moved to the head instead) and on a full queue the
+// overflow policy decides. v gets the queue's default TTL. Use OfferFront to
+// learn why v was not added. Amortized complexity: O(1).
+func (q *core[K, V]) PushFront(v V) bool {
+	return q.OfferFront(v) == Added
+}
+
+// OfferFront is PushFront reporting the outcome as Offer does.
+func (q *core[K, V]) OfferFront(v V) Result {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.insert(v, q.deadline(q.ttl), true)
+}
+
+// PopBack removes and returns the tail value, the most recently enqueued one.
+// The second result is false when the queue is empty. Amortized complexity:
+// O(1).
+func (q *core[K, V]) PopBack() (V, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	if q.len() == 0 {
+		var zero V
+		return zero, false
+	}
+	return q.popBack(), true
+}
+
+// PeekBack returns the tail value without removing it.
+// The second result is false when the queue is empty. Complexity: O(1).
+func (q *core[K, V]) PeekBack() (V, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	if q.len() == 0 {
+		var zero V
+		return zero, false
+	}
+	return q.data.slot(q.data.tail - 1).v, true
+}
+
+// PeekAt returns the i-th value counted from the head (PeekAt(0) is Peek)
+// without removing it. The second result is false when i is out of range.
+// Complexity: O(1), or O(i) while values removed from the middle of the queue
+// still await compaction.
+func (q *core[K, V]) PeekAt(i int) (V, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	var zero V
+	if i < 0 || i >= q.len() {
+		return zero, false
+	}
+	if q.dead == 0 {
+		return q.data.at(i).v, true
+	}
+	for p := q.data.head; ; p++ {
+		if e := q.data.slot(p); e.exp != tombstone {
+			if i == 0 {
+				return e.v, true
+			}
+			i--
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package xyqueue
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestDequeOps(t *testing.T) {
+	q := New[int](false)
+	q.EnqueueMany(2, 3)
+	q.PushFront(1)
+	q.PushFront(0)
+	if got := q.ToSlice(); len(got) != 4 || got[0] != 0 || got[3] != 3 {
+		t.Fatalf("ToSlice() = %v want [0 1 2 3]", got)
+	}
+	if v, ok := q.PeekBack(); !ok || v != 3 {
+		t.Fatalf("PeekBack() = %d,%v want 3,true", v, ok)
+	}
+	for i := 0; i < 4; i++ {
+		if v, ok := q.PeekAt(i); !ok || v != i {
+			t.Fatalf("PeekAt(%d) = %d,%v", i, v, ok)
+		}
+	}
+	if _, ok := q.PeekAt(4); ok {
+		t.Fatal("PeekAt past the tail should report false")
+	}
+	if _, ok := q.PeekAt(-1); ok {
+		t.Fatal("PeekAt(-1) should report false")
+	}
+	if v, ok := q.PopBack(); !ok || v != 3 {
+		t.Fatalf("PopBack() = %d,%v want 3,true", v, ok)
+	}
+	if v, _ := q.Dequeue(); v != 0 {
+		t.Fatalf("Dequeue() = %d want 0", v)
+	}
+	q.Clear()
+	if _, ok := q.PopBack(); ok {
+		t.Fatal("PopBack on empty queue should report false")
+	}
+	if _, ok := q.PeekBack(); ok {
+		t.Fatal("PeekBack on empty queue should report false")
+	}
+}
+
+func TestPushFrontDedup(t *testing.T) {
+	q := New[string](true, WithMaxSize(3, Reject))
+	q.EnqueueMany("a", "b")
+	if q.PushFront("b") {
+		t.Fatal("PushFront of a queued value should be ignored")
+	}
+	q.PushFront("c")
+	if q.PushFront("d") {
+		t.Fatal("PushFront on a full queue should be rejected")
+	}
+	if got := q.ToSlice(); len(got) != 3 || got[0] != "c" || got[1] != "a" || got[2] != "b" {
+		t.Fatalf("ToSlice() = %v want [c a b]", got)
+	}
+	if v, _ := q.PopBack(); v != "b" || q.Contains("b") {
+		t.Fatal("PopBack must clear the dedup index")
+	}
+
+	m := New[string](true, WithDuplicatePolicy(MoveToBack))
+	m.EnqueueMany("a", "b", "c")
+	m.PushFront("c")
+	if got := m.ToSlice(); got[0] != "c" || got[1] != "a" || got[2] != "b" {
+		t.Fatalf("ToSlice() = %v want [c a b]", got)
+	}
+}
+
+func TestPeekAtSkipsRemoved(t *testing.T) {
+	q := New[int](true)
+	q.EnqueueMany(0, 1, 2, 3, 4, 5)
+	q.Remove(2)
+	q.Remove(4)
+	want := []int{0, 1, 3, 5}
+	for i, w := range want {
+		if v, ok := q.PeekAt(i); !ok || v != w {
+			t.Fatalf("PeekAt(%d) = %d,%v want %d", i, v, ok, w)
+		}
+	}
+}
+
+func TestPushFrontExpiryOrder(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	q := New[int](false, WithTTL(time.Second), WithClock(clk.now))
+	q.Enqueue(1)
+	clk.advance(500 * time.Millisecond)
+	q.PushFront(0) // expires after the value behind it
+	clk.advance(600 * time.Millisecond)
+	if got := q.ToSlice(); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("ToSlice() = %v want [0]", got)
+	}
+}
+
+func TestIndexedDequeMatchesModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	q := New[int](false, WithIndex())
+	var model []int
+	for i := 0; i < 20000; i++ {
+		v := rng.Intn(8)
+		switch rng.Intn(6) {
+		case 0:
+			q.Enqueue(v)
+			model = append(model, v)
+		case 1:
+			q.PushFront(v)
+			model = append([]int{v}, model...)
+		case 2:
+			got, ok := q.PopBack()
+			if ok != (len(model) > 0) || ok && got != model[len(model)-1] {
+				t.Fatalf("step %d: PopBack() = %d,%v model %v", i, got, ok, model)
+			}
+			if ok {
+				model = model[:len(model)-1]
+			}
+		case 3:
+			got, ok := q.Dequeue()
+			if ok != (len(model) > 0) || ok && got != model[0] {
+				t.Fatalf("step %d: Dequeue() = %d,%v model %v", i, got, ok, model)
+			}
+			if ok {
+				model = model[1:]
+			}
+		default:
+			want := slicesContains(model, v)
+			if q.Remove(v) != want {
+				t.Fatalf("step %d: Remove(%d) != %v", i, v, want)
+			}
+			for j, x := range model {
+				if x == v {
+					model = append(model[:j:j], model[j+1:]...)
+					break
+				}
+			}
+		}
+		checkIndex(t, q)
+		if n := len(model); n > 0 {
+			j := rng.Intn(n)
+			if got, _ := q.PeekAt(j); got != model[j] {
+				t.Fatalf("step %d: PeekAt(%d) = %d model %v", i, j, got, model)
+			}
+		}
+	}
+}
@@ -5,5 +5,15 @@
 // NewWithCapacity. When de-duplication is enabled, Enqueue skips values that
 // are already present; once a value is removed (via Dequeue or Remove), it may
 // be enqueued again.
+//
+// Values may carry a time to live, set per value with EnqueueWithTTL or for
+// the whole queue with WithTTL. Expired values are purged lazily, the next
+// time the queue is used, and are never returned or counted.
+//
+// KeyedQueue stores values of any type and de-duplicates them by a key
+// derived from each value, with O(1) lookup and removal by key.
+//
+// PriorityQueue offers the same de-duplication semantics with heap ordering by
+// numeric priority (smallest first, FIFO among equals).
 package xyqueue
 
@@ -158,7 +158,45 @@ func Example_blockingWrapper_cond() {
     // hello true
 }
 
+// Example of a bounded queue that evicts the oldest value when full, and of
+// Offer reporting why a value was not added.
+func Example_bounded() {
+    q := New[string](true, WithMaxSize(2, DropOldest))
+    q.EnqueueMany("a", "b", "c") // "a" is evicted to make room for "c"
+    fmt.Println(q.ToSlice())
+    fmt.Println(q.Offer("c"))
+
+    r := New[string](false, WithMaxSize(2, Reject))
+    added, rejected := r.OfferMany("x", "y", "z")
+    fmt.Println(added, rejected)
+    fmt.Println(r.Offer("w"))
+    // Output:
+    // [b c]
+    // Duplicate
+    // 2 [z]
+    // Full
+}
+
 // Example using a comparable struct type.
+func Example_ttl() {
+    start := time.Unix(0, 0)
+    now := start
+    q := New[string](true, WithTTL(time.Minute), WithClock(func() time.Time { return now }))
+    q.SetExpiryFunc(func(v string) { fmt.Println("expired:", v) })
+    q.Enqueue("refresh-cache")
+    q.EnqueueWithTTL("user-request", 5*time.Second)
+
+    now = start.Add(10 * time.Second)
+    fmt.Println(q.Len())
+    now = start.Add(2 * time.Minute)
+    fmt.Println(q.Len())
+    // Output:
+    // expired: user-request
+    // 1
+    // expired: refresh-cache
+    // 0
+}
+
 func Example_structType() {
     type user struct {
         ID   int
@@ -172,3 +210,51 @@ func Example_structType() {
     // Output:
     // 2
 }
+
+// Example for DequeueMany and DequeueManyInto.
+func Example_dequeueMany() {
+    q := New[int](false)
+    q.EnqueueMany(1, 2, 3, 4, 5)
+    fmt.Println(q.DequeueMany(2))
+    buf := make([]int, 0, 16)
+    buf = q.DequeueManyInto(buf[:0], 0) // 0: no limit
+    fmt.Println(buf)
+    // Output:
+    // [1 2]
+    // [3 4 5]
+}
+
+// Example for the All and Drain iterators.
+func Example_iterators() {
+    q := New[string](false)
+    q.EnqueueMany("a", "b", "c")
+    for v := range q.All() { // read-only, no copy
+        fmt.Print(v, " ")
+    }
+    fmt.Println(q.Len())
+    for v := range q.Drain() { // dequeues as it goes
+        fmt.Print(v, " ")
+    }
+    fmt.Println(q.Len())
+    // Output:
+    // a b c 3
+    // a b c 0
+}
+
+// Example for PriorityQueue with de-duplication and UpdatePriority.
+func Example_priorityQueue() {
+    pq := NewPriorityQueue[string](true)
+    pq.EnqueuePriority("report", 5)
+    pq.EnqueuePriority("email", 5)
+    pq.EnqueuePriority("alert", 1)
+    pq.EnqueuePriority("alert", 9) // ignored: already present
+    pq.UpdatePriority("email", 0)
+    for !pq.IsEmpty() {
+        v, _ := pq.Dequeue()
+        fmt.Println(v)
+    }
+    // Output:
+    // email
+    // alert
+    // report
+}
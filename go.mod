@@ -1,3 +1,3 @@
 module github.com/xyhelper/xyqueue
 
-go 1.22
+go 1.23
@@ -0,0 +1,140 @@
+package xyqueue
+
+import "slices"
+
+// occurrences lists, in queue order, the ring positions of the values that
+// share one key in an indexed queue without de-duplication. Values leave a
+// key's list only from its front (Dequeue and Remove take the first
+// occurrence) or its back (DropNewest evicts the last), so both are O(1)
+// amortized.
+type occurrences struct {
+	pos  []uint64
+	head int // pos[:head] have left the queue
+}
+
+func (o *occurrences) len() int { return len(o.pos) - o.head }
+
+func (o *occurrences) first() uint64 { return o.pos[o.head] }
+
+func (o *occurrences) popFront() {
+	o.head++
+	if o.head == len(o.pos) {
+		o.pos, o.head = o.pos[:0], 0
+	} else if o.head >= len(o.pos)/2 {
+		// Reclaim the dead prefix; the copy is paid for by the pops that
+		// created it.
+		n := copy(o.pos, o.pos[o.head:])
+		o.pos, o.head = o.pos[:n], 0
+	}
+}
+
+func (o *occurrences) popBack() { o.pos = o.pos[:len(o.pos)-1] }
+
+// indexed reports whether the queue keeps an index by key. q.mu need not be
+// held.
+func (q *core[K, V]) indexed() bool { return q.index != nil || q.multi != nil }
+
+// track records that the value with key k is at ring position p, which must
+// be past every position already recorded for k. q.mu must be held.
+func (q *core[K, V]) track(k K, p uint64) {
+	switch {
+	case q.index != nil:
+		q.index[k] = p
+	case q.multi != nil:
+		o := q.multi[k]
+		if o == nil {
+			o, _ = q.spare.Get().(*occurrences)
+			if o == nil {
+				o = new(occurrences)
+			}
+			q.multi[k] = o
+		}
+		o.pos = append(o.pos, p)
+	}
+}
+
+// trackFront is like track for a value inserted before every position
+// already recorded for k. With WithIndex this costs O(m) for a key that is
+// queued m times, unless a slot freed at the front of its list is reused.
+// q.mu must be held.
+func (q *core[K, V]) trackFront(k K, p uint64) {
+	if q.multi == nil {
+		q.track(k, p)
+		return
+	}
+	o := q.multi[k]
+	if o == nil || o.len() == 0 {
+		q.track(k, p)
+		return
+	}
+	if o.head > 0 {
+		o.head--
+		o.pos[o.head] = p
+		return
+	}
+	o.pos = slices.Insert(o.pos, 0, p)
+}
+
+// untrack forgets that v is at ring position p, which must be the first or
+// the last position recorded for its key. q.mu must be held.
+func (q *core[K, V]) untrack(v V, p uint64) {
+	switch {
+	case q.index != nil:
+		delete(q.index, q.key(v))
+	case q.multi != nil:
+		k := q.key(v)
+		o := q.multi[k]
+		if o.first() == p {
+			o.popFront()
+		} else {
+			o.popBack()
+		}
+		if o.len() == 0 {
+			delete(q.multi, k)
+			q.spare.Put(o)
+		}
+	}
+}
+
+// reindex rebuilds the index from scratch after the ring was compacted and
+// positions changed. q.mu must be held.
+func (q *core[K, V]) reindex() {
+	switch {
+	case q.index != nil:
+		clear(q.index)
+	case q.multi != nil:
+		for _, o := range q.multi {
+			o.pos, o.head = o.pos[:0], 0
+			q.spare.Put(o)
+		}
+		clear(q.multi)
+	default:
+		return
+	}
+	for p := q.data.head; p != q.data.tail; p++ {
+		if e := q.data.slot(p); e.exp != tombstone {
+			q.track(q.key(e.v), p)
+		}
+	}
+}
+
+// find returns the ring position of the first value with key k. q.mu must be
+// held.
+func (q *core[K, V]) find(k K) (uint64, bool) {
+	switch {
+	case q.index != nil:
+		p, ok := q.index[k]
+		return p, ok
+	case q.multi != nil:
+		if o := q.multi[k]; o != nil {
+			return o.first(), true
+		}
+		return 0, false
+	}
+	for p := q.data.head; p != q.data.tail; p++ {
+		if e := q.data.slot(p); e.exp != tombstone && q.key(e.v) == k {
+			return p, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package xyqueue
+
+import "iter"
+
+// All returns an iterator over the queued values in FIFO order, without
+// copying the queue and without holding its lock while the loop body runs, so
+// the body may freely call methods on q (including Enqueue and Dequeue).
+//
+// Iteration is weakly consistent: it starts at the current head, skips values
+// dequeued in the meantime and includes values enqueued before it reaches
+// the tail. A concurrent Remove of a middle value, or expiry of values out of
+// queue order, may cause a neighbouring value to be skipped or seen twice.
+// Expired values are not yielded. Each step costs one lock acquisition.
+func (q *Queue[T]) All() iter.Seq[T] {
+	return q.core.All()
+}
+
+// Drain returns an iterator that dequeues values from the head and yields
+// them until the queue is empty or the loop stops. Each value is removed
+// before it is yielded, so breaking out of the loop keeps the remaining
+// values queued but not the one just received.
+func (q *Queue[T]) Drain() iter.Seq[T] {
+	return q.core.Drain()
+}
+
+// All returns an iterator over the queued values in FIFO order, without
+// copying the queue and without holding its lock while the loop body runs, so
+// the body may freely call methods on q (including Enqueue and Dequeue).
+//
+// Iteration is weakly consistent: it starts at the current head, skips values
+// dequeued in the meantime and includes values enqueued before it reaches
+// the tail. A concurrent Remove of a middle value, or expiry of values out of
+// queue order, may cause a neighbouring value to be skipped or seen twice.
+// Expired values are not yielded. Each step costs one lock acquisition.
+func (q *core[K, V]) All() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		q.mu.Lock()
+		p := q.data.head
+		q.mu.Unlock()
+		for {
+			q.mu.Lock()
+			q.expire()
+			if int64(p-q.data.head) < 0 {
+				p = q.data.head // values before p were dequeued
+			}
+			if int64(q.data.tail-p) <= 0 {
+				q.mu.Unlock()
+				return
+			}
+			e := *q.data.slot(p)
+			q.mu.Unlock()
+			p++
+			if e.exp == tombstone {
+				continue
+			}
+			if !yield(e.v) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues values from the head and yields
+// them until the queue is empty or the loop stops. Each value is removed
+// before it is yielded, so breaking out of the loop keeps the remaining
+// values queued but not the one just received.
+func (q *core[K, V]) Drain() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for {
+			v, ok := q.Dequeue()
+			if !ok || !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package xyqueue
+
+import "testing"
+
+func TestAllSeesLiveQueue(t *testing.T) {
+	q := New[int](false)
+	q.EnqueueMany(1, 2, 3)
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+		// Mutating the queue from the loop body must not deadlock.
+		if v == 1 {
+			q.Dequeue() // removes 1, already yielded
+			q.Enqueue(4)
+		}
+	}
+	want := []int{1, 2, 3, 4}
+	if len(got) != len(want) {
+		t.Fatalf("All() yielded %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("All() yielded %v want %v", got, want)
+		}
+	}
+	if q.Len() != 3 {
+		t.Fatalf("len=%d want 3 (All must not remove)", q.Len())
+	}
+}
+
+func TestAllSkipsDequeued(t *testing.T) {
+	q := New[int](false)
+	q.EnqueueMany(1, 2, 3, 4)
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+		if v == 1 {
+			q.DequeueMany(3) // drop 1, 2 and 3
+		}
+	}
+	if len(got) != 2 || got[1] != 4 {
+		t.Fatalf("All() yielded %v want [1 4]", got)
+	}
+	q.Clear()
+	for range q.All() {
+		t.Fatal("All() on empty queue yielded a value")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	q := New[string](true)
+	q.EnqueueMany("a", "b", "c")
+	var got []string
+	for v := range q.Drain() {
+		got = append(got, v)
+		if v == "b" {
+			break
+		}
+	}
+	if len(got) != 2 || q.Len() != 1 {
+		t.Fatalf("drained %v, len %d", got, q.Len())
+	}
+	if !q.Enqueue("a") {
+		t.Fatal("drained value should be re-enqueueable")
+	}
+}
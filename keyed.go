@@ -0,0 +1,51 @@
+package xyqueue
+
+// KeyedQueue is a concurrency-safe FIFO queue of arbitrary values that
+// de-duplicates by a key derived from each value, so the payload itself need
+// not be comparable (it may hold slices or maps). While a value with some key
+// is queued, enqueuing another value with the same key is ignored; once it
+// leaves the queue the key can be enqueued again.
+//
+// The full value is stored, and Contains, Get and Remove look it up by key in
+// O(1). KeyedQueue accepts the same options as Queue (bounding, overflow
+// policy, TTL) and otherwise behaves like a Queue with de-duplication enabled.
+// The zero value is not ready for use; construct via NewKeyed or
+// NewKeyedWithCapacity.
+type KeyedQueue[K comparable, V any] struct {
+	core[K, V]
+}
+
+// NewKeyed creates a keyed queue that de-duplicates by key(v). key must be
+// deterministic, and a queued value must not be modified in a way that changes
+// its key. NewKeyed panics if key is nil.
+func NewKeyed[K comparable, V any](key func(V) K, opts ...Option) *KeyedQueue[K, V] {
+	return NewKeyedWithCapacity(key, 0, opts...)
+}
+
+// NewKeyedWithCapacity is like NewKeyed but preallocates storage for capacity
+// values, as NewWithCapacity does for Queue.
+func NewKeyedWithCapacity[K comparable, V any](key func(V) K, capacity int, opts ...Option) *KeyedQueue[K, V] {
+	if key == nil {
+		panic("xyqueue: NewKeyed called with a nil key function")
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+	q := &KeyedQueue[K, V]{}
+	q.init(key, true, capacity, opts)
+	return q
+}
+
+// Get returns the queued value with key k without removing it. The second
+// result is false when no such value is queued. Complexity: O(1).
+func (q *KeyedQueue[K, V]) Get(k K) (V, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	p, ok := q.find(k)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return q.data.slot(p).v, true
+}
@@ -0,0 +1,247 @@
+package xyqueue
+
+import (
+	"testing"
+	"time"
+)
+
+type job struct {
+	ID   string
+	Args []string
+	Meta map[string]string
+}
+
+func jobID(j job) string { return j.ID }
+
+func ids(js []job) []string {
+	out := make([]string, len(js))
+	for i, j := range js {
+		out[i] = j.ID
+	}
+	return out
+}
+
+func TestKeyedDedupByKey(t *testing.T) {
+	q := NewKeyed(jobID)
+	if !q.Enqueue(job{ID: "a", Args: []string{"1"}}) {
+		t.Fatal("first enqueue of a should succeed")
+	}
+	if q.Enqueue(job{ID: "a", Args: []string{"2"}}) {
+		t.Fatal("second enqueue of key a should be ignored")
+	}
+	if r := q.Offer(job{ID: "a"}); r != Duplicate {
+		t.Fatalf("Offer = %v want Duplicate", r)
+	}
+	q.Enqueue(job{ID: "b", Meta: map[string]string{"k": "v"}})
+	if q.Len() != 2 {
+		t.Fatalf("len = %d want 2", q.Len())
+	}
+	j, ok := q.Get("a")
+	if !ok || len(j.Args) != 1 || j.Args[0] != "1" {
+		t.Fatalf("Get(a) = %+v,%v want the first payload", j, ok)
+	}
+	if _, ok := q.Get("zzz"); ok {
+		t.Fatal("Get of an absent key should report false")
+	}
+	if !q.Contains("b") || q.Contains("c") {
+		t.Fatal("Contains mismatch")
+	}
+	v, ok := q.Dequeue()
+	if !ok || v.ID != "a" {
+		t.Fatalf("Dequeue() = %+v,%v want a", v, ok)
+	}
+	if !q.Enqueue(job{ID: "a"}) {
+		t.Fatal("key a should be enqueueable again after Dequeue")
+	}
+	if got := ids(q.ToSlice()); len(got) != 2 || got[0] != "b" || got[1] != "a" {
+		t.Fatalf("ToSlice() = %v want [b a]", got)
+	}
+}
+
+func TestKeyedRemove(t *testing.T) {
+	q := NewKeyed(jobID)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		q.Enqueue(job{ID: id})
+	}
+	if !q.Remove("b") {
+		t.Fatal("Remove(b) should succeed")
+	}
+	if q.Remove("b") {
+		t.Fatal("second Remove(b) should report false")
+	}
+	if q.Contains("b") || q.Len() != 3 {
+		t.Fatalf("after Remove contains(b)=%v len=%d", q.Contains("b"), q.Len())
+	}
+	if !q.Enqueue(job{ID: "b"}) {
+		t.Fatal("removed key should be enqueueable again")
+	}
+	if got := ids(q.ToSlice()); len(got) != 4 || got[0] != "a" || got[1] != "c" || got[2] != "d" || got[3] != "b" {
+		t.Fatalf("ToSlice() = %v want [a c d b]", got)
+	}
+	var seen []string
+	for j := range q.All() {
+		seen = append(seen, j.ID)
+	}
+	if len(seen) != 4 || seen[1] != "c" {
+		t.Fatalf("All() = %v want [a c d b]", seen)
+	}
+}
+
+// TestKeyedRemoveCompaction removes most values from the middle so that
+// tombstones are compacted away, then checks that lookups still find the
+// survivors at their new positions.
+func TestKeyedRemoveCompaction(t *testing.T) {
+	q := NewKeyed(func(v int) int { return v })
+	const n = 1000
+	for i := 0; i < n; i++ {
+		q.Enqueue(i)
+	}
+	for i := 1; i < n-1; i++ {
+		if i%10 != 0 && !q.Remove(i) {
+			t.Fatalf("Remove(%d) failed", i)
+		}
+	}
+	if q.dead > q.len() {
+		t.Fatalf("dead = %d exceeds live = %d; tombstones were not compacted", q.dead, q.len())
+	}
+	for i := 0; i < n; i++ {
+		want := i == 0 || i == n-1 || i%10 == 0
+		if q.Contains(i) != want {
+			t.Fatalf("Contains(%d) = %v want %v", i, !want, want)
+		}
+		if v, ok := q.Get(i); ok != want || (ok && v != i) {
+			t.Fatalf("Get(%d) = %d,%v", i, v, ok)
+		}
+	}
+	prev := -1
+	for {
+		v, ok := q.Dequeue()
+		if !ok {
+			break
+		}
+		if v <= prev {
+			t.Fatalf("FIFO order broken: %d after %d", v, prev)
+		}
+		prev = v
+	}
+	if prev != n-1 || q.dead != 0 || q.data.len() != 0 {
+		t.Fatalf("last = %d dead = %d ring len = %d", prev, q.dead, q.data.len())
+	}
+}
+
+func TestKeyedOptions(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	var evicted []string
+	q := NewKeyed(jobID, WithMaxSize(2, Reject), WithTTL(time.Second), WithClock(clk.now))
+	q.SetOverflowFunc(func(j job) OverflowPolicy {
+		evicted = append(evicted, j.ID)
+		return DropOldest
+	})
+	q.EnqueueMany(job{ID: "a"}, job{ID: "b"}, job{ID: "c"})
+	if got := ids(q.ToSlice()); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Fatalf("ToSlice() = %v want [b c]", got)
+	}
+	if len(evicted) != 1 || evicted[0] != "c" || q.Contains("a") {
+		t.Fatalf("evicted = %v contains(a) = %v", evicted, q.Contains("a"))
+	}
+	clk.advance(time.Second)
+	if !q.IsEmpty() || q.Contains("b") {
+		t.Fatal("values should have expired")
+	}
+}
+
+func TestNewKeyedNilKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for nil key function")
+		}
+	}()
+	NewKeyed[string, job](nil)
+}
+
+type setting struct {
+	Key   string
+	Value int
+	Tags  []string
+}
+
+func TestKeyedCoalesce(t *testing.T) {
+	q := NewKeyed(func(s setting) string { return s.Key }, WithDuplicatePolicy(Coalesce))
+	if r := q.Offer(setting{Key: "a", Value: 1}); r != Added {
+		t.Fatalf("Offer(a=1) = %v want Added", r)
+	}
+	q.Offer(setting{Key: "b", Value: 1})
+	if r := q.Offer(setting{Key: "a", Value: 2}); r != Replaced {
+		t.Fatalf("Offer(a=2) = %v want Replaced", r)
+	}
+	if q.Enqueue(setting{Key: "a", Value: 3}) {
+		t.Fatal("Enqueue of a queued key should report false")
+	}
+	if q.Len() != 2 {
+		t.Fatalf("len = %d want 2", q.Len())
+	}
+	got := q.ToSlice()
+	if got[0].Key != "a" || got[0].Value != 3 || got[1].Key != "b" {
+		t.Fatalf("ToSlice() = %+v want a=3 first, then b", got)
+	}
+}
+
+func TestKeyedMergeFunc(t *testing.T) {
+	q := NewKeyed(func(s setting) string { return s.Key })
+	q.SetMergeFunc(func(old, new setting) setting {
+		new.Tags = append(old.Tags, new.Tags...)
+		return new
+	})
+	q.Enqueue(setting{Key: "a", Tags: []string{"x"}})
+	if r := q.Offer(setting{Key: "a", Value: 7, Tags: []string{"y"}}); r != Replaced {
+		t.Fatalf("Offer = %v want Replaced", r)
+	}
+	s, _ := q.Get("a")
+	if s.Value != 7 || len(s.Tags) != 2 || s.Tags[0] != "x" || s.Tags[1] != "y" {
+		t.Fatalf("merged = %+v", s)
+	}
+}
+
+func TestCoalesceBoundedFull(t *testing.T) {
+	// Coalescing never needs room, so a full queue still accepts updates to
+	// keys it holds.
+	q := NewKeyed(func(s setting) string { return s.Key },
+		WithMaxSize(1, Reject), WithDuplicatePolicy(Coalesce))
+	q.Enqueue(setting{Key: "a", Value: 1})
+	if r := q.Offer(setting{Key: "b"}); r != Full {
+		t.Fatalf("Offer(b) = %v want Full", r)
+	}
+	if r := q.Offer(setting{Key: "a", Value: 2}); r != Replaced {
+		t.Fatalf("Offer(a) = %v want Replaced", r)
+	}
+	if s, _ := q.Peek(); s.Value != 2 {
+		t.Fatalf("Peek() = %+v want a=2", s)
+	}
+}
+
+func TestDuplicatePolicyDefault(t *testing.T) {
+	q := New[int](true)
+	q.Enqueue(1)
+	if r := q.Offer(1); r != Duplicate {
+		t.Fatalf("Offer = %v want Duplicate", r)
+	}
+	if KeepExisting.String() != "KeepExisting" || Coalesce.String() != "Coalesce" || Replaced.String() != "Replaced" {
+		t.Fatal("unexpected String() output")
+	}
+}
+
+func TestKeyedMoveToBack(t *testing.T) {
+	q := NewKeyed(func(s setting) string { return s.Key }, WithDuplicatePolicy(MoveToBack))
+	q.Enqueue(setting{Key: "a", Value: 1})
+	q.Enqueue(setting{Key: "b", Value: 1})
+	if r := q.Offer(setting{Key: "a", Value: 2}); r != Moved {
+		t.Fatalf("Offer(a) = %v want Moved", r)
+	}
+	got := q.ToSlice()
+	if len(got) != 2 || got[0].Key != "b" || got[1].Key != "a" || got[1].Value != 2 {
+		t.Fatalf("ToSlice() = %+v want b, then a=2", got)
+	}
+	if s, ok := q.Get("a"); !ok || s.Value != 2 {
+		t.Fatalf("Get(a) = %+v,%v want a=2", s, ok)
+	}
+}
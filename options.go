@@ -0,0 +1,160 @@
+package xyqueue
+
+import (
+	"fmt"
+	"time"
+)
+
+// OverflowPolicy selects what a bounded queue does when adding a value would
+// exceed its maximum size.
+type OverflowPolicy uint8
+
+const (
+	// Reject refuses the new value; the queue is left unchanged.
+	Reject OverflowPolicy = iota
+	// DropOldest evicts the head (the oldest value) to make room.
+	DropOldest
+	// DropNewest evicts the tail (the most recently enqueued value) to make
+	// room, so the new value takes its place at the back of the queue.
+	DropNewest
+)
+
+// String returns the policy name.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Reject:
+		return "Reject"
+	case DropOldest:
+		return "DropOldest"
+	case DropNewest:
+		return "DropNewest"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", uint8(p))
+}
+
+// Result reports the outcome of offering a value to a queue.
+type Result uint8
+
+const (
+	// Added means the value was appended to the queue.
+	Added Result = iota
+	// Duplicate means de-duplication is enabled and the value was already
+	// present, so it was ignored.
+	Duplicate
+	// Full means the queue is at its maximum size and the overflow policy
+	// rejected the value.
+	Full
+	// Replaced means de-duplication is enabled with the Coalesce policy and
+	// the value took the place of (or was merged into) the queued value with
+	// the same key, which kept its position.
+	Replaced
+	// Moved means de-duplication is enabled with the MoveToBack policy and
+	// the queued value with the same key was replaced by this one at the tail.
+	Moved
+)
+
+// String returns the result name.
+func (r Result) String() string {
+	switch r {
+	case Added:
+		return "Added"
+	case Duplicate:
+		return "Duplicate"
+	case Full:
+		return "Full"
+	case Replaced:
+		return "Replaced"
+	case Moved:
+		return "Moved"
+	}
+	return fmt.Sprintf("Result(%d)", uint8(r))
+}
+
+// DuplicatePolicy selects what a de-duplicating queue does when a value is
+// enqueued while a value with the same key is already queued.
+type DuplicatePolicy uint8
+
+const (
+	// KeepExisting ignores the new value; the result is Duplicate. This is
+	// the default.
+	KeepExisting DuplicatePolicy = iota
+	// Coalesce replaces the queued value with the new one (or with the result
+	// of the merge function, see SetMergeFunc) without moving it; the result
+	// is Replaced. The queued value keeps its expiry deadline.
+	Coalesce
+	// MoveToBack removes the queued value and appends the new one at the
+	// tail, as a Remove followed by an Enqueue would, but atomically and
+	// without being subject to the overflow policy; the result is Moved. The
+	// new value gets a fresh expiry deadline. This suits LRU-style work
+	// lists, where enqueuing again "touches" an entry.
+	MoveToBack
+)
+
+// String returns the policy name.
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case KeepExisting:
+		return "KeepExisting"
+	case Coalesce:
+		return "Coalesce"
+	case MoveToBack:
+		return "MoveToBack"
+	}
+	return fmt.Sprintf("DuplicatePolicy(%d)", uint8(p))
+}
+
+// Option configures a queue at construction time. Pass options to New or
+// NewWithCapacity.
+type Option func(*options)
+
+type options struct {
+	maxSize   int
+	overflow  OverflowPolicy
+	duplicate DuplicatePolicy
+	indexed   bool
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// WithMaxSize bounds the queue to at most n elements. When an Enqueue would
+// exceed n, policy decides whether the new value is rejected or an existing
+// one is evicted; SetOverflowFunc can decide per value instead. n <= 0 means
+// unbounded, which is the default.
+func WithMaxSize(n int, policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.maxSize = n
+		o.overflow = policy
+	}
+}
+
+// WithDuplicatePolicy selects what happens when a value is enqueued while one
+// with the same key is queued. It only matters when de-duplication is enabled
+// (always the case for KeyedQueue); the default is KeepExisting.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(o *options) { o.duplicate = p }
+}
+
+// WithIndex makes a queue without de-duplication keep an index of where
+// each value is queued, so that Contains and Remove take O(1) (amortized)
+// instead of scanning the queue. The index costs memory and a map update
+// per Enqueue and Dequeue, so it pays off when Remove or Contains is called
+// often, for example to cancel queued jobs. Queues with de-duplication are
+// always indexed, so the option has no effect on them.
+func WithIndex() Option {
+	return func(o *options) { o.indexed = true }
+}
+
+// WithTTL gives every value enqueued without an explicit TTL a lifetime of d.
+// Once a value has been queued for d it expires: it is no longer returned,
+// counted or reported as present, and is purged the next time the queue is
+// used. d <= 0 means values never expire, which is the default. See also
+// Queue.EnqueueWithTTL.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) { o.ttl = d }
+}
+
+// WithClock replaces time.Now as the source of the current time for TTL
+// deadlines, mainly so tests can control expiry. A nil now restores time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
@@ -0,0 +1,89 @@
+package xyqueue
+
+// The predicate-based operations below run atomically under the queue's
+// lock. The predicate is called with the lock held and must not call methods
+// on the queue.
+
+// RemoveFunc deletes every queued value for which pred returns true and
+// returns the number deleted. The remaining values keep their order.
+// Complexity: O(n), with a single pass over the queue.
+func (q *Queue[T]) RemoveFunc(pred func(v T) bool) int {
+	return q.core.RemoveFunc(pred)
+}
+
+// RetainFunc deletes every queued value for which keep returns false and
+// returns the number deleted; it is RemoveFunc with the predicate negated.
+// Complexity: O(n).
+func (q *Queue[T]) RetainFunc(keep func(v T) bool) int {
+	return q.core.RetainFunc(keep)
+}
+
+// DequeueIf removes and returns the head value if pred reports true for it.
+// The second result is false when the queue is empty or pred rejected the
+// head, which then stays queued. Complexity: O(1).
+func (q *Queue[T]) DequeueIf(pred func(v T) bool) (T, bool) {
+	return q.core.DequeueIf(pred)
+}
+
+// FindFunc returns the first value, in FIFO order, for which pred returns
+// true, without removing it. The second result is false if there is none.
+// Complexity: O(n) in the worst case.
+func (q *Queue[T]) FindFunc(pred func(v T) bool) (T, bool) {
+	return q.core.FindFunc(pred)
+}
+
+// RemoveFunc deletes every queued value for which pred returns true and
+// returns the number deleted. The remaining values keep their order.
+// Complexity: O(n), with a single pass over the queue.
+func (q *core[K, V]) RemoveFunc(pred func(v V) bool) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	n, dead := q.len(), q.dead
+	q.data.filter(func(e *entry[V]) bool {
+		return e.exp != tombstone && !pred(e.v)
+	})
+	q.dead = 0
+	removed := n - q.len()
+	if removed > 0 || dead > 0 {
+		q.reindex() // positions shifted
+	}
+	return removed
+}
+
+// RetainFunc deletes every queued value for which keep returns false and
+// returns the number deleted; it is RemoveFunc with the predicate negated.
+// Complexity: O(n).
+func (q *core[K, V]) RetainFunc(keep func(v V) bool) int {
+	return q.RemoveFunc(func(v V) bool { return !keep(v) })
+}
+
+// DequeueIf removes and returns the head value if pred reports true for it.
+// The second result is false when the queue is empty or pred rejected the
+// head, which then stays queued. Complexity: O(1).
+func (q *core[K, V]) DequeueIf(pred func(v V) bool) (V, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	if q.len() == 0 || !pred(q.data.at(0).v) {
+		var zero V
+		return zero, false
+	}
+	return q.popFront(), true
+}
+
+// FindFunc returns the first value, in FIFO order, for which pred returns
+// true, without removing it. The second result is false if there is none.
+// Complexity: O(n) in the worst case.
+func (q *core[K, V]) FindFunc(pred func(v V) bool) (V, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	for p := q.data.head; p != q.data.tail; p++ {
+		if e := q.data.slot(p); e.exp != tombstone && pred(e.v) {
+			return e.v, true
+		}
+	}
+	var zero V
+	return zero, false
+}
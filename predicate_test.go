@@ -0,0 +1,73 @@
+package xyqueue
+
+import "testing"
+
+func isEven(v int) bool { return v%2 == 0 }
+
+func TestRemoveFunc(t *testing.T) {
+	q := New[int](true)
+	q.EnqueueMany(1, 2, 3, 4, 5, 6)
+	q.Remove(3) // leave a tombstone behind
+	if n := q.RemoveFunc(isEven); n != 3 {
+		t.Fatalf("RemoveFunc = %d want 3", n)
+	}
+	if got := q.ToSlice(); len(got) != 2 || got[0] != 1 || got[1] != 5 {
+		t.Fatalf("ToSlice() = %v want [1 5]", got)
+	}
+	if q.Contains(2) || !q.Contains(5) {
+		t.Fatal("dedup index out of sync after RemoveFunc")
+	}
+	if !q.Enqueue(2) || q.Enqueue(5) {
+		t.Fatal("removed values must be enqueueable again, remaining ones not")
+	}
+	if !q.Remove(5) {
+		t.Fatal("Remove(5) should find the value at its new position")
+	}
+	if n := q.RemoveFunc(func(int) bool { return false }); n != 0 {
+		t.Fatalf("RemoveFunc(none) = %d want 0", n)
+	}
+}
+
+func TestRetainFunc(t *testing.T) {
+	q := New[int](false, WithIndex())
+	q.EnqueueMany(1, 2, 2, 3, 4)
+	if n := q.RetainFunc(isEven); n != 2 {
+		t.Fatalf("RetainFunc = %d want 2", n)
+	}
+	if got := q.ToSlice(); len(got) != 3 || got[0] != 2 || got[1] != 2 || got[2] != 4 {
+		t.Fatalf("ToSlice() = %v want [2 2 4]", got)
+	}
+	checkIndex(t, q)
+}
+
+func TestDequeueIf(t *testing.T) {
+	q := New[int](true)
+	if _, ok := q.DequeueIf(isEven); ok {
+		t.Fatal("DequeueIf on empty queue should report false")
+	}
+	q.EnqueueMany(2, 3)
+	if v, ok := q.DequeueIf(isEven); !ok || v != 2 {
+		t.Fatalf("DequeueIf = %d,%v want 2,true", v, ok)
+	}
+	if _, ok := q.DequeueIf(isEven); ok {
+		t.Fatal("odd head must not be dequeued")
+	}
+	if q.Len() != 1 || !q.Contains(3) || q.Contains(2) {
+		t.Fatalf("queue = %v", q.ToSlice())
+	}
+}
+
+func TestFindFunc(t *testing.T) {
+	q := NewKeyed(jobID)
+	q.EnqueueMany(job{ID: "a"}, job{ID: "b", Args: []string{"x"}}, job{ID: "c", Args: []string{"y"}})
+	j, ok := q.FindFunc(func(j job) bool { return len(j.Args) > 0 })
+	if !ok || j.ID != "b" {
+		t.Fatalf("FindFunc = %+v,%v want b", j, ok)
+	}
+	if _, ok := q.FindFunc(func(j job) bool { return j.ID == "z" }); ok {
+		t.Fatal("FindFunc should report false when nothing matches")
+	}
+	if q.Len() != 3 {
+		t.Fatal("FindFunc must not remove anything")
+	}
+}
@@ -0,0 +1,311 @@
+package xyqueue
+
+import (
+	"slices"
+	"sync"
+)
+
+// PriorityQueue is a generic, concurrency-safe priority queue with optional
+// de-duplication, backed by a binary heap.
+//
+// Values are dequeued in ascending order of their numeric priority, so the
+// smallest priority comes out first. Values with equal priority are ordered by
+// the less function given to NewPriorityQueueFunc, if any, and otherwise (or
+// when less reports neither is smaller) in the order they were enqueued.
+//
+// De-duplication works as for Queue: while a value is present, enqueuing it
+// again is ignored. The presence set doubles as a heap index, which makes
+// Contains, Remove and UpdatePriority O(log n) or better in that mode. The
+// zero value is not ready for use; construct via NewPriorityQueue or
+// NewPriorityQueueFunc.
+type PriorityQueue[T comparable] struct {
+	mu    sync.Mutex
+	heap  []pqEntry[T]
+	index map[T]int // heap position of each value; only used when dedup is true
+	less  func(a, b T) bool
+	seq   uint64
+	dedup bool
+}
+
+type pqEntry[T comparable] struct {
+	v    T
+	prio int64
+	seq  uint64 // insertion order, for FIFO among equals
+}
+
+// NewPriorityQueue creates a priority queue ordered by the explicit priority
+// passed to EnqueuePriority. When dedup is true, values already present are
+// ignored by Enqueue and EnqueuePriority.
+func NewPriorityQueue[T comparable](dedup bool) *PriorityQueue[T] {
+	return NewPriorityQueueFunc[T](dedup, nil)
+}
+
+// NewPriorityQueueFunc creates a priority queue that orders values with equal
+// priority by less, where less(a, b) reports whether a should be dequeued
+// before b. Callers that only use Enqueue (priority 0) get a queue ordered
+// purely by less. A nil less falls back to FIFO among equal priorities.
+func NewPriorityQueueFunc[T comparable](dedup bool, less func(a, b T) bool) *PriorityQueue[T] {
+	pq := &PriorityQueue[T]{less: less, dedup: dedup}
+	if dedup {
+		pq.index = make(map[T]int)
+	}
+	return pq
+}
+
+// Enqueue adds v with priority 0. It returns false when de-duplication is
+// enabled and v is already present. Complexity: O(log n).
+func (pq *PriorityQueue[T]) Enqueue(v T) bool {
+	return pq.EnqueuePriority(v, 0)
+}
+
+// EnqueuePriority adds v with the given priority; smaller priorities are
+// dequeued first. It returns false when de-duplication is enabled and v is
+// already present (use UpdatePriority to change its priority).
+// Complexity: O(log n).
+func (pq *PriorityQueue[T]) EnqueuePriority(v T, priority int64) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if pq.dedup {
+		if _, exists := pq.index[v]; exists {
+			return false
+		}
+	}
+	pq.seq++
+	pq.heap = append(pq.heap, pqEntry[T]{v: v, prio: priority, seq: pq.seq})
+	i := len(pq.heap) - 1
+	pq.setIndex(i)
+	pq.up(i)
+	return true
+}
+
+// Dequeue removes and returns the value with the smallest priority.
+// The second result is false when the queue is empty. Complexity: O(log n).
+func (pq *PriorityQueue[T]) Dequeue() (T, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.heap) == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.removeAt(0).v, true
+}
+
+// Peek returns the value with the smallest priority without removing it.
+// The second result is false when the queue is empty. Complexity: O(1).
+func (pq *PriorityQueue[T]) Peek() (T, bool) {
+	v, _, ok := pq.PeekPriority()
+	return v, ok
+}
+
+// PeekPriority is like Peek but also returns the head's priority.
+func (pq *PriorityQueue[T]) PeekPriority() (T, int64, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.heap) == 0 {
+		var zero T
+		return zero, 0, false
+	}
+	return pq.heap[0].v, pq.heap[0].prio, true
+}
+
+// Priority returns the priority of v if it is present. Complexity: O(1) when
+// de-duplication is enabled; otherwise O(n), reporting the first match found.
+func (pq *PriorityQueue[T]) Priority(v T) (int64, bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	i := pq.find(v)
+	if i < 0 {
+		return 0, false
+	}
+	return pq.heap[i].prio, true
+}
+
+// UpdatePriority changes the priority of v if it is present and reports
+// whether it was. v keeps its original position among values of equal
+// priority. Complexity: O(log n) when de-duplication is enabled; otherwise
+// O(n) to find v (one arbitrary occurrence is updated).
+func (pq *PriorityQueue[T]) UpdatePriority(v T, priority int64) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	i := pq.find(v)
+	if i < 0 {
+		return false
+	}
+	pq.heap[i].prio = priority
+	pq.fix(i)
+	return true
+}
+
+// Len returns the number of elements currently queued.
+// Complexity: O(1).
+func (pq *PriorityQueue[T]) Len() int {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return len(pq.heap)
+}
+
+// IsEmpty reports whether the queue is empty.
+// Complexity: O(1). Equivalent to Len() == 0.
+func (pq *PriorityQueue[T]) IsEmpty() bool {
+	return pq.Len() == 0
+}
+
+// Contains reports whether v is currently present in the queue.
+// Complexity: O(1) when de-duplication is enabled; otherwise O(n).
+func (pq *PriorityQueue[T]) Contains(v T) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return pq.find(v) >= 0
+}
+
+// Remove deletes v from the queue if present and reports whether it was.
+// Complexity: O(log n) when de-duplication is enabled; otherwise O(n) to find
+// v (one arbitrary occurrence is removed).
+func (pq *PriorityQueue[T]) Remove(v T) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	i := pq.find(v)
+	if i < 0 {
+		return false
+	}
+	pq.removeAt(i)
+	return true
+}
+
+// Clear removes all elements from the queue.
+// Complexity: O(n).
+func (pq *PriorityQueue[T]) Clear() {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	clear(pq.heap)
+	pq.heap = pq.heap[:0]
+	if pq.dedup {
+		clear(pq.index)
+	}
+}
+
+// ToSlice returns a copy of the queue's contents in dequeue order.
+// Complexity: O(n log n). The returned slice is independent of the queue.
+func (pq *PriorityQueue[T]) ToSlice() []T {
+	pq.mu.Lock()
+	entries := slices.Clone(pq.heap)
+	pq.mu.Unlock()
+	slices.SortFunc(entries, func(a, b pqEntry[T]) int {
+		switch {
+		case pq.before(a, b):
+			return -1
+		case pq.before(b, a):
+			return 1
+		}
+		return 0
+	})
+	out := make([]T, len(entries))
+	for i, e := range entries {
+		out[i] = e.v
+	}
+	return out
+}
+
+// find returns the heap position of v, or -1. pq.mu must be held.
+func (pq *PriorityQueue[T]) find(v T) int {
+	if pq.dedup {
+		if i, ok := pq.index[v]; ok {
+			return i
+		}
+		return -1
+	}
+	for i := range pq.heap {
+		if pq.heap[i].v == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeAt deletes and returns the entry at heap position i, zeroing the
+// vacated slot. pq.mu must be held.
+func (pq *PriorityQueue[T]) removeAt(i int) pqEntry[T] {
+	e := pq.heap[i]
+	last := len(pq.heap) - 1
+	if i != last {
+		pq.swap(i, last)
+	}
+	pq.heap[last] = pqEntry[T]{}
+	pq.heap = pq.heap[:last]
+	if i != last {
+		pq.fix(i)
+	}
+	if pq.dedup {
+		delete(pq.index, e.v)
+	}
+	if cap(pq.heap) > minRingSize && len(pq.heap) <= cap(pq.heap)/4 {
+		pq.heap = slices.Clone(pq.heap)
+	}
+	return e
+}
+
+// before reports whether entry a must be dequeued before b.
+func (pq *PriorityQueue[T]) before(a, b pqEntry[T]) bool {
+	if a.prio != b.prio {
+		return a.prio < b.prio
+	}
+	if pq.less != nil {
+		if pq.less(a.v, b.v) {
+			return true
+		}
+		if pq.less(b.v, a.v) {
+			return false
+		}
+	}
+	return a.seq < b.seq
+}
+
+func (pq *PriorityQueue[T]) setIndex(i int) {
+	if pq.dedup {
+		pq.index[pq.heap[i].v] = i
+	}
+}
+
+func (pq *PriorityQueue[T]) swap(i, j int) {
+	pq.heap[i], pq.heap[j] = pq.heap[j], pq.heap[i]
+	pq.setIndex(i)
+	pq.setIndex(j)
+}
+
+// fix restores the heap order after the entry at i changed.
+func (pq *PriorityQueue[T]) fix(i int) {
+	if !pq.down(i) {
+		pq.up(i)
+	}
+}
+
+func (pq *PriorityQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !pq.before(pq.heap[i], pq.heap[parent]) {
+			return
+		}
+		pq.swap(i, parent)
+		i = parent
+	}
+}
+
+// down sifts the entry at i towards the leaves and reports whether it moved.
+func (pq *PriorityQueue[T]) down(i int) bool {
+	start, n := i, len(pq.heap)
+	for {
+		child := 2*i + 1
+		if child >= n {
+			break
+		}
+		if r := child + 1; r < n && pq.before(pq.heap[r], pq.heap[child]) {
+			child = r
+		}
+		if !pq.before(pq.heap[child], pq.heap[i]) {
+			break
+		}
+		pq.swap(i, child)
+		i = child
+	}
+	return i > start
+}
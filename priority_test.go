@@ -0,0 +1,116 @@
+package xyqueue
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func drainPQ[T comparable](pq *PriorityQueue[T]) []T {
+	var out []T
+	for {
+		v, ok := pq.Dequeue()
+		if !ok {
+			return out
+		}
+		out = append(out, v)
+	}
+}
+
+func TestPriorityOrderAndStability(t *testing.T) {
+	pq := NewPriorityQueue[string](false)
+	pq.EnqueuePriority("low-1", 5)
+	pq.EnqueuePriority("high", 1)
+	pq.EnqueuePriority("low-2", 5)
+	pq.EnqueuePriority("mid", 3)
+	pq.EnqueuePriority("low-3", 5)
+	if v, p, _ := pq.PeekPriority(); v != "high" || p != 1 {
+		t.Fatalf("PeekPriority() = %q,%d want high,1", v, p)
+	}
+	if got := pq.ToSlice(); got[0] != "high" || got[4] != "low-3" {
+		t.Fatalf("ToSlice() = %v", got)
+	}
+	got := drainPQ(pq)
+	want := []string{"high", "mid", "low-1", "low-2", "low-3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v want %v", got, want)
+		}
+	}
+}
+
+func TestPriorityRandomStable(t *testing.T) {
+	type item struct{ id, prio int }
+	pq := NewPriorityQueue[item](true)
+	rnd := rand.New(rand.NewSource(1))
+	var items []item
+	for i := 0; i < 500; i++ {
+		it := item{i, rnd.Intn(10)}
+		items = append(items, it)
+		pq.EnqueuePriority(it, int64(it.prio))
+	}
+	// Remove and reprioritize some, mirroring the changes in items.
+	for i := 0; i < 500; i += 7 {
+		if !pq.Remove(items[i]) {
+			t.Fatalf("Remove(%v) failed", items[i])
+		}
+		items[i].id = -1
+	}
+	sort.SliceStable(items, func(a, b int) bool { return items[a].prio < items[b].prio })
+	got := drainPQ(pq)
+	j := 0
+	for _, it := range items {
+		if it.id < 0 {
+			continue
+		}
+		if got[j] != it {
+			t.Fatalf("position %d = %v want %v", j, got[j], it)
+		}
+		j++
+	}
+	if j != len(got) {
+		t.Fatalf("dequeued %d want %d", len(got), j)
+	}
+}
+
+func TestPriorityDedupAndUpdate(t *testing.T) {
+	pq := NewPriorityQueue[string](true)
+	pq.EnqueuePriority("a", 1)
+	pq.EnqueuePriority("b", 2)
+	pq.EnqueuePriority("c", 3)
+	if pq.EnqueuePriority("c", 0) {
+		t.Fatal("expected duplicate to be ignored")
+	}
+	if !pq.UpdatePriority("c", 0) {
+		t.Fatal("expected UpdatePriority to find c")
+	}
+	if pq.UpdatePriority("z", 0) {
+		t.Fatal("UpdatePriority of absent value must fail")
+	}
+	if p, ok := pq.Priority("c"); !ok || p != 0 {
+		t.Fatalf("Priority(c) = %d,%v want 0,true", p, ok)
+	}
+	pq.UpdatePriority("a", 9)
+	got := drainPQ(pq)
+	if len(got) != 3 || got[0] != "c" || got[1] != "b" || got[2] != "a" {
+		t.Fatalf("order = %v want [c b a]", got)
+	}
+	if !pq.Enqueue("a") || pq.Contains("b") {
+		t.Fatal("presence set not cleaned up on Dequeue")
+	}
+}
+
+func TestPriorityLessFunc(t *testing.T) {
+	pq := NewPriorityQueueFunc[int](false, func(a, b int) bool { return a > b })
+	for _, v := range []int{3, 1, 4, 1, 5} {
+		pq.Enqueue(v)
+	}
+	pq.EnqueuePriority(0, -1) // explicit priority still wins over less
+	got := drainPQ(pq)
+	want := []int{0, 5, 4, 3, 1, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v want %v", got, want)
+		}
+	}
+}
@@ -1,190 +1,749 @@
 package xyqueue
 
 import (
+	"slices"
 	"sync"
+	"time"
 )
 
 // Queue is a generic, concurrency-safe FIFO queue with optional de-duplication.
 // When de-duplication is enabled, Enqueue ignores values already present in the
 // queue. After a value is removed (via Dequeue/Remove), it can be enqueued
-// again. The zero value is not ready for use; construct via New or
+// again. A queue may also be bounded (see WithMaxSize), in which case an
+// overflow policy decides what happens when it is full. Values may be given a
+// time to live (see WithTTL and EnqueueWithTTL), after which they expire and
+// are dropped. The zero value is not ready for use; construct via New or
 // NewWithCapacity.
 type Queue[T comparable] struct {
-	mu    sync.Mutex
-	data  []T
-	set   map[T]struct{} // only used when dedup is true
-	dedup bool
+	core core[T, T] // a keyed queue whose key is the value itself
 }
 
 // New creates a new queue.
 //
 // When dedup is true, repeated Enqueue of the same value while it is present in
-// the queue is ignored. All exported methods are safe for concurrent use.
-func New[T comparable](dedup bool) *Queue[T] {
-	q := &Queue[T]{
-		data:  make([]T, 0),
-		dedup: dedup,
-	}
-	if dedup {
-		q.set = make(map[T]struct{})
-	}
-	return q
+// the queue is ignored. opts configure optional behavior such as a maximum
+// size. All exported methods are safe for concurrent use.
+func New[T comparable](dedup bool, opts ...Option) *Queue[T] {
+	return newQueue[T](dedup, 0, opts)
 }
 
 // NewWithCapacity creates a new queue with the given initial capacity.
-// Capacity preallocates internal storage; behavior is otherwise identical to
-// New. When dedup is true, the presence set is also allocated.
-func NewWithCapacity[T comparable](dedup bool, capacity int) *Queue[T] {
+// Capacity preallocates internal storage (rounded up to a power of two) and is
+// also the size below which the storage never shrinks; behavior is otherwise
+// identical to New. When dedup is true, the presence index is also allocated.
+func NewWithCapacity[T comparable](dedup bool, capacity int, opts ...Option) *Queue[T] {
 	if capacity < 0 {
 		capacity = 0
 	}
-	q := &Queue[T]{
-		data:  make([]T, 0, capacity),
-		dedup: dedup,
-	}
-	if dedup {
-		q.set = make(map[T]struct{}, capacity)
-	}
+	return newQueue[T](dedup, capacity, opts)
+}
+
+func newQueue[T comparable](dedup bool, capacity int, opts []Option) *Queue[T] {
+	q := &Queue[T]{}
+	q.core.init(identity[T], dedup, capacity, opts)
 	return q
 }
 
+func identity[T any](v T) T { return v }
+
 // Enqueue appends v to the tail.
 //
 // Returns true if the value was added, or false when de-duplication is enabled
-// and v is already present. Amortized complexity: O(1).
+// and v is already present, or when the queue is full and its overflow policy
+// rejects v. Use Offer to tell those cases apart. Amortized complexity: O(1);
+// storage only grows when the length reaches the next power of two.
 func (q *Queue[T]) Enqueue(v T) bool {
+	return q.core.Enqueue(v)
+}
+
+// EnqueueWithTTL appends v to the tail like Enqueue, but v expires once ttl
+// has elapsed, overriding the queue's default TTL. ttl <= 0 means v never
+// expires. Complexity: as for Enqueue.
+func (q *Queue[T]) EnqueueWithTTL(v T, ttl time.Duration) bool {
+	return q.core.EnqueueWithTTL(v, ttl)
+}
+
+// Offer appends v to the tail and reports the outcome: Added, Duplicate when
+// de-duplication is enabled and v is already present, Replaced or Moved when
+// the queued v was coalesced with v or moved to the tail instead (see
+// WithDuplicatePolicy), or Full when the queue is at its maximum size and the
+// overflow policy rejects v. With DropOldest or DropNewest an existing value
+// is evicted instead and the result is Added. Complexity: O(1).
+func (q *Queue[T]) Offer(v T) Result {
+	return q.core.Offer(v)
+}
+
+// EnqueueMany enqueues items and returns the count actually added.
+//
+// When de-duplication is enabled, values already present are skipped and order
+// of first occurrences is preserved. On a bounded queue each item is subject
+// to the overflow policy in turn; use OfferMany to learn which were rejected.
+// Amortized complexity: O(k) for k items.
+func (q *Queue[T]) EnqueueMany(items ...T) int {
+	return q.core.EnqueueMany(items...)
+}
+
+// OfferMany enqueues items atomically, in order, and reports the count added
+// together with the items refused because the queue was full (in their
+// original order). Duplicates skipped, coalesced or moved by de-duplication
+// are in neither.
+// Complexity: O(k) for k items.
+func (q *Queue[T]) OfferMany(items ...T) (added int, rejected []T) {
+	return q.core.OfferMany(items...)
+}
+
+// MaxSize returns the maximum number of elements the queue holds, or 0 when
+// it is unbounded.
+func (q *Queue[T]) MaxSize() int {
+	return q.core.MaxSize()
+}
+
+// SetOverflowFunc installs a callback that chooses the overflow policy for
+// each value that arrives while a bounded queue is full, overriding the policy
+// given to WithMaxSize, or removes it when fn is nil. It has no effect on
+// unbounded queues.
+//
+// fn is called with the queue's lock held and must not call methods on the
+// queue.
+func (q *Queue[T]) SetOverflowFunc(fn func(v T) OverflowPolicy) {
+	q.core.SetOverflowFunc(fn)
+}
+
+// SetMergeFunc selects the Coalesce duplicate policy with fn deciding the
+// value that remains queued: fn receives the queued value and the newly
+// enqueued one and returns the value to keep. In a Queue the two are equal
+// and so must be the result, which makes merging more useful in a
+// KeyedQueue. A nil fn keeps Coalesce but lets the new value replace the
+// queued one.
+//
+// fn is called with the queue's lock held and must not call methods on the
+// queue.
+func (q *Queue[T]) SetMergeFunc(fn func(old, new T) T) {
+	q.core.SetMergeFunc(fn)
+}
+
+// SetExpiryFunc installs a callback that receives each value purged because
+// its TTL elapsed, or removes it when fn is nil. Values evicted by the
+// overflow policy or removed explicitly are not reported.
+//
+// fn is called with the queue's lock held and must not call methods on the
+// queue.
+func (q *Queue[T]) SetExpiryFunc(fn func(v T)) {
+	q.core.SetExpiryFunc(fn)
+}
+
+// Dequeue removes and returns the head value.
+//
+// The second result is false when the queue is empty. The vacated slot is
+// zeroed so the queue does not keep v reachable. Amortized complexity: O(1).
+func (q *Queue[T]) Dequeue() (T, bool) {
+	return q.core.Dequeue()
+}
+
+// DequeueMany removes and returns up to max values from the head, in FIFO
+// order, atomically under a single lock acquisition. max <= 0 means no limit.
+// The result is empty (nil) when the queue is empty. Complexity: O(k) for the
+// k values returned.
+func (q *Queue[T]) DequeueMany(max int) []T {
+	return q.core.DequeueMany(max)
+}
+
+// DequeueManyInto is like DequeueMany but appends the values to dst and
+// returns the extended slice, so callers can reuse a buffer across batches:
+//
+//	buf = q.DequeueManyInto(buf[:0], 64)
+func (q *Queue[T]) DequeueManyInto(dst []T, max int) []T {
+	return q.core.DequeueManyInto(dst, max)
+}
+
+// Peek returns the head value without removing it.
+// The second result is false when the queue is empty. Complexity: O(1).
+func (q *Queue[T]) Peek() (T, bool) {
+	return q.core.Peek()
+}
+
+// Len returns the number of elements currently queued, not counting expired
+// ones. Complexity: O(1), plus the cost of purging values that just expired.
+// Safe for concurrent use.
+func (q *Queue[T]) Len() int {
+	return q.core.Len()
+}
+
+// NextExpiry reports when the queue next needs to purge expired values: a
+// time no later than the deadline of the first queued value to expire, so
+// that a caller waiting on the queue can wake up and see values go. ok is
+// false when no queued value has a TTL. Safe for concurrent use.
+func (q *Queue[T]) NextExpiry() (t time.Time, ok bool) {
+	return q.core.NextExpiry()
+}
+
+// IsEmpty reports whether the queue is empty.
+// Complexity: O(1). Equivalent to Len() == 0.
+func (q *Queue[T]) IsEmpty() bool {
+	return q.core.IsEmpty()
+}
+
+// Contains reports whether v is currently present in the queue.
+// Complexity: O(1) when de-duplication or WithIndex is enabled;
+// otherwise O(n).
+func (q *Queue[T]) Contains(v T) bool {
+	return q.core.Contains(v)
+}
+
+// Remove deletes the first occurrence of v from the queue if present.
+// Returns true if removed. Complexity: O(1) amortized when de-duplication or
+// WithIndex is enabled; otherwise O(n) to find the value. The removed value's
+// slot is reclaimed lazily, without shifting the values around it.
+func (q *Queue[T]) Remove(v T) bool {
+	return q.core.Remove(v)
+}
+
+// Clear removes all elements from the queue and releases storage grown beyond
+// the initial capacity.
+// Complexity: O(n) in the capacity of the storage plus the size of the
+// index, if any.
+func (q *Queue[T]) Clear() {
+	q.core.Clear()
+}
+
+// ToSlice returns a copy of the queue's contents in FIFO order.
+// Complexity: O(n). The returned slice is independent of the queue.
+func (q *Queue[T]) ToSlice() []T {
+	return q.core.ToSlice()
+}
+
+// core implements Queue and KeyedQueue.
+//
+// Values are kept in FIFO order in a ring. Removing a value from the middle
+// leaves a tombstone in its slot instead of shifting its neighbours; the ring
+// never starts or ends with a tombstone, and once tombstones outnumber live
+// values they are compacted away in a single pass, so removal is O(1)
+// amortized. When de-duplication is enabled, index maps the key of every
+// queued value to its position in the ring; indexed queues without
+// de-duplication keep the positions of all values sharing a key in multi
+// instead (see index.go). Either makes lookups by key O(1).
+type core[K comparable, V any] struct {
+	mu    sync.Mutex
+	data  ring[entry[V]]
+	key   func(V) K
+	index map[K]uint64 // ring position of each key; only used when dedup is true
+	multi map[K]*occurrences
+	spare sync.Pool // recycled *occurrences
+	dedup bool
+	dead  int // tombstones in data
+
+	maxSize    int // 0 means unbounded
+	overflow   OverflowPolicy
+	onOverflow func(V) OverflowPolicy
+	duplicate  DuplicatePolicy
+	merge      func(old, new V) V
+
+	ttl      time.Duration // default TTL; 0 means values never expire
+	now      func() time.Time
+	onExpire func(V)
+	nextExp  int64 // no later than the earliest queued deadline; 0 when none
+	inOrder  bool  // deadlines never decrease from head to tail
+}
+
+// entry is a queued value with its expiry deadline in Unix nanoseconds, 0 if
+// it never expires, or tombstone if the value was removed.
+type entry[V any] struct {
+	v   V
+	exp int64
+}
+
+const tombstone = -1
+
+func (q *core[K, V]) init(key func(V) K, dedup bool, capacity int, opts []Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	q.data = newRing[entry[V]](capacity)
+	q.key = key
+	q.dedup = dedup
+	q.overflow = o.overflow
+	q.duplicate = o.duplicate
+	q.now = time.Now
+	q.inOrder = true
+	if o.maxSize > 0 {
+		q.maxSize = o.maxSize
+	}
+	if o.ttl > 0 {
+		q.ttl = o.ttl
+	}
+	if o.now != nil {
+		q.now = o.now
+	}
+	switch {
+	case dedup:
+		q.index = make(map[K]uint64, capacity)
+	case o.indexed:
+		q.multi = make(map[K]*occurrences, capacity)
+	}
+}
+
+// Enqueue appends v to the tail.
+//
+// Returns true if the value was added, or false when de-duplication is enabled
+// and a value with the same key is already present, or when the queue is full
+// and its overflow policy rejects v. Use Offer to tell those cases apart.
+// Amortized complexity: O(1); storage only grows when the length reaches the
+// next power of two.
+func (q *core[K, V]) Enqueue(v V) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	if q.dedup {
-		if _, exists := q.set[v]; exists {
-			return false
-		}
-		q.set[v] = struct{}{}
-	}
-	q.data = append(q.data, v)
-	return true
+	return q.offer(v, q.deadline(q.ttl)) == Added
+}
+
+// EnqueueWithTTL appends v to the tail like Enqueue, but v expires once ttl
+// has elapsed, overriding the queue's default TTL. ttl <= 0 means v never
+// expires. Complexity: as for Enqueue.
+func (q *core[K, V]) EnqueueWithTTL(v V, ttl time.Duration) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.offer(v, q.deadline(ttl)) == Added
+}
+
+// Offer appends v to the tail and reports the outcome: Added, Duplicate when
+// de-duplication is enabled and a value with the same key is already present,
+// Replaced or Moved when that value was coalesced with v or moved to the
+// tail instead (see WithDuplicatePolicy), or Full when the queue is at its
+// maximum size and the overflow policy rejects v. With DropOldest or
+// DropNewest an existing value is evicted instead and the result is Added.
+// Complexity: O(1).
+func (q *core[K, V]) Offer(v V) Result {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.offer(v, q.deadline(q.ttl))
 }
 
 // EnqueueMany enqueues items and returns the count actually added.
 //
 // When de-duplication is enabled, values already present are skipped and order
-// of first occurrences is preserved. Amortized complexity: O(k) for k items.
-func (q *Queue[T]) EnqueueMany(items ...T) int {
+// of first occurrences is preserved. On a bounded queue each item is subject
+// to the overflow policy in turn; use OfferMany to learn which were rejected.
+// Amortized complexity: O(k) for k items.
+func (q *core[K, V]) EnqueueMany(items ...V) int {
 	added := 0
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	exp := q.deadline(q.ttl)
 	for _, v := range items {
-		if q.dedup {
-			if _, exists := q.set[v]; exists {
-				continue
-			}
-			q.set[v] = struct{}{}
+		if q.offer(v, exp) == Added {
+			added++
 		}
-		q.data = append(q.data, v)
-		added++
 	}
 	return added
 }
 
+// OfferMany enqueues items atomically, in order, and reports the count added
+// together with the items refused because the queue was full (in their
+// original order). Duplicates skipped, coalesced or moved by de-duplication
+// are in neither.
+// Complexity: O(k) for k items.
+func (q *core[K, V]) OfferMany(items ...V) (added int, rejected []V) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	exp := q.deadline(q.ttl)
+	for _, v := range items {
+		switch q.offer(v, exp) {
+		case Added:
+			added++
+		case Full:
+			rejected = append(rejected, v)
+		}
+	}
+	return added, rejected
+}
+
+// offer adds v to the tail with expiry deadline exp, applying
+// de-duplication and the overflow policy. q.mu must be held.
+func (q *core[K, V]) offer(v V, exp int64) Result {
+	return q.insert(v, exp, false)
+}
+
+// insert adds v at the tail, or at the head if front is true, with expiry
+// deadline exp, applying de-duplication and the overflow policy. q.mu must be
+// held.
+func (q *core[K, V]) insert(v V, exp int64, front bool) Result {
+	q.expire()
+	var k K
+	if q.indexed() {
+		k = q.key(v)
+	}
+	result := Added
+	if q.dedup {
+		if p, exists := q.index[k]; exists {
+			if q.duplicate != MoveToBack {
+				return q.coalesce(p, v)
+			}
+			q.kill(p) // frees the room v needs
+			result = Moved
+		}
+	}
+	if q.maxSize > 0 && q.len() >= q.maxSize {
+		policy := q.overflow
+		if q.onOverflow != nil {
+			policy = q.onOverflow(v)
+		}
+		switch policy {
+		case DropOldest:
+			q.popFront()
+		case DropNewest:
+			q.popBack()
+		default:
+			return Full
+		}
+	}
+	if q.len() == 0 {
+		q.nextExp, q.inOrder = 0, true
+	} else if front && !expiresBy(exp, q.data.at(0).exp) ||
+		!front && !expiresBy(q.data.slot(q.data.tail-1).exp, exp) {
+		q.inOrder = false
+	}
+	if exp != 0 && (q.nextExp == 0 || exp < q.nextExp) {
+		q.nextExp = exp
+	}
+	if front {
+		q.trackFront(k, q.data.head-1)
+		q.data.pushFront(entry[V]{v: v, exp: exp})
+	} else {
+		q.track(k, q.data.tail)
+		q.data.pushBack(entry[V]{v: v, exp: exp})
+	}
+	return result
+}
+
+// expiresBy reports whether deadline a is no later than deadline b, where 0
+// means never.
+func expiresBy(a, b int64) bool {
+	return b == 0 || a != 0 && a <= b
+}
+
+// coalesce applies the KeepExisting or Coalesce policy to v, whose key is
+// already queued at ring position p. q.mu must be held.
+func (q *core[K, V]) coalesce(p uint64, v V) Result {
+	if q.duplicate != Coalesce {
+		return Duplicate
+	}
+	e := q.data.slot(p)
+	if q.merge != nil {
+		v = q.merge(e.v, v)
+	}
+	e.v = v
+	return Replaced
+}
+
+// len returns the number of live values. q.mu must be held.
+func (q *core[K, V]) len() int { return q.data.len() - q.dead }
+
+// popFront removes and returns the head value. The queue must not be empty.
+// q.mu must be held.
+func (q *core[K, V]) popFront() V {
+	p := q.data.head
+	v := q.data.popFront().v
+	q.untrack(v, p)
+	if q.dead > 0 {
+		q.trim()
+	}
+	return v
+}
+
+// popBack removes and returns the tail value. The queue must not be empty.
+// q.mu must be held.
+func (q *core[K, V]) popBack() V {
+	p := q.data.tail - 1
+	v := q.data.popBack().v
+	q.untrack(v, p)
+	if q.dead > 0 {
+		q.trim()
+	}
+	return v
+}
+
+// kill removes the value at ring position p by turning its slot into a
+// tombstone, and returns it. q.mu must be held.
+func (q *core[K, V]) kill(p uint64) V {
+	e := q.data.slot(p)
+	v := e.v
+	q.untrack(v, p)
+	*e = entry[V]{exp: tombstone}
+	q.dead++
+	q.trim()
+	if q.dead > q.len() {
+		q.compact()
+	}
+	return v
+}
+
+// trim pops tombstones off both ends of the ring, so the head and tail slots
+// always hold live values. q.mu must be held.
+func (q *core[K, V]) trim() {
+	for q.dead > 0 && q.data.at(0).exp == tombstone {
+		q.data.popFront()
+		q.dead--
+	}
+	for q.dead > 0 && q.data.slot(q.data.tail-1).exp == tombstone {
+		q.data.popBack()
+		q.dead--
+	}
+}
+
+// compact squeezes the tombstones out of the ring. q.mu must be held.
+func (q *core[K, V]) compact() {
+	q.data.filter(func(e *entry[V]) bool { return e.exp != tombstone })
+	q.dead = 0
+	q.reindex()
+}
+
+// deadline returns the expiry deadline for a value enqueued now with the
+// given TTL, or 0 if ttl <= 0. q.mu must be held.
+func (q *core[K, V]) deadline(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return q.now().Add(ttl).UnixNano()
+}
+
+// expire purges the values whose deadline has passed, reporting each to the
+// expiry callback. It only reads the clock while some value has a deadline
+// and only looks at the values once the earliest deadline has passed. While
+// deadlines are in queue order (always the case with a single default TTL)
+// the expired values form a prefix and are popped in O(1) each; otherwise
+// the whole queue is compacted in one O(n) pass. q.mu must be held.
+func (q *core[K, V]) expire() {
+	if q.nextExp == 0 {
+		return
+	}
+	now := q.now().UnixNano()
+	if now < q.nextExp {
+		return
+	}
+	if q.inOrder {
+		for q.len() > 0 {
+			if exp := q.data.at(0).exp; exp == 0 || exp > now {
+				break
+			}
+			q.expired(q.popFront())
+		}
+		q.nextExp = 0
+		if q.len() > 0 {
+			q.nextExp = q.data.at(0).exp
+		}
+		return
+	}
+	q.nextExp = 0
+	q.data.filter(func(e *entry[V]) bool {
+		switch {
+		case e.exp == tombstone:
+			return false
+		case e.exp == 0:
+			return true
+		case e.exp <= now:
+			q.expired(e.v)
+			return false
+		}
+		if q.nextExp == 0 || e.exp < q.nextExp {
+			q.nextExp = e.exp
+		}
+		return true
+	})
+	q.dead = 0
+	q.reindex()
+}
+
+// expired reports v, whose TTL elapsed and which was already removed, to the
+// expiry callback. q.mu must be held.
+func (q *core[K, V]) expired(v V) {
+	if q.onExpire != nil {
+		q.onExpire(v)
+	}
+}
+
+// MaxSize returns the maximum number of elements the queue holds, or 0 when
+// it is unbounded.
+func (q *core[K, V]) MaxSize() int {
+	return q.maxSize
+}
+
+// SetOverflowFunc installs a callback that chooses the overflow policy for
+// each value that arrives while a bounded queue is full, overriding the policy
+// given to WithMaxSize, or removes it when fn is nil. It has no effect on
+// unbounded queues.
+//
+// fn is called with the queue's lock held and must not call methods on the
+// queue.
+func (q *core[K, V]) SetOverflowFunc(fn func(v V) OverflowPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onOverflow = fn
+}
+
+// SetMergeFunc selects the Coalesce duplicate policy with fn deciding the
+// value that remains queued: fn receives the queued value and the newly
+// enqueued one and returns their combination. The result must have the same
+// key as old. A nil fn keeps Coalesce but lets the new value replace the
+// queued one.
+//
+// fn is called with the queue's lock held and must not call methods on the
+// queue.
+func (q *core[K, V]) SetMergeFunc(fn func(old, new V) V) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.duplicate = Coalesce
+	q.merge = fn
+}
+
+// SetExpiryFunc installs a callback that receives each value purged because
+// its TTL elapsed, or removes it when fn is nil. Values evicted by the
+// overflow policy or removed explicitly are not reported.
+//
+// fn is called with the queue's lock held and must not call methods on the
+// queue.
+func (q *core[K, V]) SetExpiryFunc(fn func(v V)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onExpire = fn
+}
+
 // Dequeue removes and returns the head value.
 //
-// The second result is false when the queue is empty. Amortized complexity: O(1).
-func (q *Queue[T]) Dequeue() (T, bool) {
+// The second result is false when the queue is empty. The vacated slot is
+// zeroed so the queue does not keep v reachable. Amortized complexity: O(1).
+func (q *core[K, V]) Dequeue() (V, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	var zero T
-	if len(q.data) == 0 {
+	q.expire()
+	var zero V
+	if q.len() == 0 {
 		return zero, false
 	}
-	v := q.data[0]
-	// Avoid O(n) element moves by reslicing; let GC reclaim older head when needed.
-	q.data = q.data[1:]
-	if q.dedup {
-		delete(q.set, v)
+	return q.popFront(), true
+}
+
+// DequeueMany removes and returns up to max values from the head, in FIFO
+// order, atomically under a single lock acquisition. max <= 0 means no limit.
+// The result is empty (nil) when the queue is empty. Complexity: O(k) for the
+// k values returned.
+func (q *core[K, V]) DequeueMany(max int) []V {
+	return q.DequeueManyInto(nil, max)
+}
+
+// DequeueManyInto is like DequeueMany but appends the values to dst and
+// returns the extended slice, so callers can reuse a buffer across batches:
+//
+//	buf = q.DequeueManyInto(buf[:0], 64)
+func (q *core[K, V]) DequeueManyInto(dst []V, max int) []V {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	n := q.len()
+	if max > 0 && max < n {
+		n = max
 	}
-	return v, true
+	dst = slices.Grow(dst, n)
+	for i := 0; i < n; i++ {
+		dst = append(dst, q.popFront())
+	}
+	return dst
 }
 
 // Peek returns the head value without removing it.
 // The second result is false when the queue is empty. Complexity: O(1).
-func (q *Queue[T]) Peek() (T, bool) {
+func (q *core[K, V]) Peek() (V, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	var zero T
-	if len(q.data) == 0 {
+	q.expire()
+	var zero V
+	if q.len() == 0 {
 		return zero, false
 	}
-	return q.data[0], true
+	return q.data.at(0).v, true
 }
 
-// Len returns the number of elements currently queued.
-// Complexity: O(1). Safe for concurrent use.
-func (q *Queue[T]) Len() int {
+// Len returns the number of elements currently queued, not counting expired
+// ones. Complexity: O(1), plus the cost of purging values that just expired.
+// Safe for concurrent use.
+func (q *core[K, V]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	return q.len()
+}
+
+// NextExpiry reports when the queue next needs to purge expired values: a
+// time no later than the deadline of the first queued value to expire, so
+// that a caller waiting on the queue can wake up and see values go. ok is
+// false when no queued value has a TTL. Safe for concurrent use.
+func (q *core[K, V]) NextExpiry() (t time.Time, ok bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	return len(q.data)
+	q.expire()
+	if q.nextExp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, q.nextExp), true
 }
 
 // IsEmpty reports whether the queue is empty.
 // Complexity: O(1). Equivalent to Len() == 0.
-func (q *Queue[T]) IsEmpty() bool {
+func (q *core[K, V]) IsEmpty() bool {
 	return q.Len() == 0
 }
 
-// Contains reports whether v is currently present in the queue.
-// Complexity: O(1) when de-duplication is enabled; otherwise O(n).
-func (q *Queue[T]) Contains(v T) bool {
+// Contains reports whether a value with key k is currently present in the
+// queue. Complexity: O(1) when de-duplication or WithIndex is enabled;
+// otherwise O(n).
+func (q *core[K, V]) Contains(k K) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	if q.dedup {
-		_, ok := q.set[v]
-		return ok
-	}
-	for _, x := range q.data {
-		if x == v {
-			return true
-		}
-	}
-	return false
+	q.expire()
+	_, ok := q.find(k)
+	return ok
 }
 
-// Remove deletes the first occurrence of v from the queue if present.
-// Returns true if removed. Complexity: O(n).
-func (q *Queue[T]) Remove(v T) bool {
+// Remove deletes the first value with key k from the queue if present.
+// Returns true if removed. Complexity: O(1) amortized when de-duplication or
+// WithIndex is enabled; otherwise O(n) to find the value. The removed value's
+// slot is reclaimed lazily, without shifting the values around it.
+func (q *core[K, V]) Remove(k K) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	for i, x := range q.data {
-		if x == v {
-			// remove q.data[i]
-			copy(q.data[i:], q.data[i+1:])
-			q.data = q.data[:len(q.data)-1]
-			if q.dedup {
-				delete(q.set, v)
-			}
-			return true
-		}
+	q.expire()
+	p, ok := q.find(k)
+	if !ok {
+		return false
 	}
-	return false
+	q.kill(p)
+	return true
 }
 
-// Clear removes all elements from the queue.
-// Complexity: O(1) for the data slice; clearing the presence set (when
-// de-duplication is enabled) is O(n) in the number of elements.
-func (q *Queue[T]) Clear() {
+// Clear removes all elements from the queue and releases storage grown beyond
+// the initial capacity.
+// Complexity: O(n) in the capacity of the storage plus the size of the
+// index, if any.
+func (q *core[K, V]) Clear() {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	q.data = q.data[:0]
-	if q.dedup {
-		clear(q.set)
-	}
+	q.data.reset()
+	q.dead = 0
+	q.nextExp, q.inOrder = 0, true
+	clear(q.index)
+	clear(q.multi)
 }
 
 // ToSlice returns a copy of the queue's contents in FIFO order.
 // Complexity: O(n). The returned slice is independent of the queue.
-func (q *Queue[T]) ToSlice() []T {
+func (q *core[K, V]) ToSlice() []V {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	out := make([]T, len(q.data))
-	copy(out, q.data)
+	q.expire()
+	out := make([]V, 0, q.len())
+	for p := q.data.head; p != q.data.tail; p++ {
+		if e := q.data.slot(p); e.exp != tombstone {
+			out = append(out, e.v)
+		}
+	}
 	return out
 }
@@ -64,3 +64,122 @@ func BenchmarkContains_NonDedup(b *testing.B) {
     }
 }
 
+func BenchmarkContains_Indexed(b *testing.B) {
+    q := New[int](false, WithIndex())
+    for i := 0; i < 50_000; i++ {
+        q.Enqueue(i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        _ = q.Contains(i % 50_000)
+    }
+}
+
+// benchRemove cancels queued jobs from the middle of a queue of n values,
+// enqueuing a new job for each one removed so the length stays constant.
+func benchRemove(b *testing.B, n int, opts ...Option) {
+    q := New[int](false, opts...)
+    for i := 0; i < n; i++ {
+        q.Enqueue(i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        if !q.Remove(i + n/2) {
+            b.Fatalf("Remove(%d) found nothing", i+n/2)
+        }
+        q.Enqueue(i + n)
+    }
+}
+
+func BenchmarkRemove_NonDedup(b *testing.B) { benchRemove(b, 10_000) }
+
+func BenchmarkRemove_Indexed(b *testing.B) { benchRemove(b, 10_000, WithIndex()) }
+
+func BenchmarkRemove_Dedup(b *testing.B) {
+    q := New[int](true)
+    for i := 0; i < 10_000; i++ {
+        q.Enqueue(i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        q.Remove(i + 5_000)
+        q.Enqueue(i + 10_000)
+    }
+}
+
+// Steady churn with an index: occurrence lists are recycled, so this should
+// also report zero allocations per operation.
+func BenchmarkEnqueueDequeue_SteadyIndexed(b *testing.B) {
+    q := New[int](false, WithIndex())
+    for i := 0; i < 1024; i++ {
+        q.Enqueue(i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        q.Enqueue(i)
+        q.Dequeue()
+    }
+}
+
+// Steady churn at a constant length: the ring reuses its slots, so this
+// should report zero allocations per operation.
+func BenchmarkEnqueueDequeue_Steady(b *testing.B) {
+    q := New[int](false)
+    for i := 0; i < 1024; i++ {
+        q.Enqueue(i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        q.Enqueue(i)
+        q.Dequeue()
+    }
+}
+
+// The same workload on a reslicing slice (the previous storage), for
+// comparison: the backing array is periodically reallocated and copied even
+// though the length never changes.
+func BenchmarkSliceReslice_Steady(b *testing.B) {
+    data := make([]int, 0, 1024)
+    for i := 0; i < 1024; i++ {
+        data = append(data, i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        data = append(data, i)
+        data = data[1:]
+    }
+}
+
+// Bursts that fill the queue and drain it completely, exercising growth and
+// shrinking of the ring.
+func BenchmarkFillDrain(b *testing.B) {
+    q := New[int](false)
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for j := 0; j < 4096; j++ {
+            q.Enqueue(j)
+        }
+        for j := 0; j < 4096; j++ {
+            q.Dequeue()
+        }
+    }
+}
+
+func BenchmarkPeek(b *testing.B) {
+    q := New[int](false)
+    for i := 0; i < 1024; i++ {
+        q.Enqueue(i)
+    }
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        _, _ = q.Peek()
+    }
+}
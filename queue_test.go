@@ -1,10 +1,12 @@
 package xyqueue
 
 import (
+	"math/rand"
 	"runtime"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestFIFO(t *testing.T) {
@@ -117,3 +119,520 @@ func TestConcurrentDedup(t *testing.T) {
 		}
 	}
 }
+
+func TestRingWrapAround(t *testing.T) {
+	q := NewWithCapacity[int](false, 4)
+	next, want := 0, 0
+	// Keep the length oscillating between 3 and 6 so head and tail wrap many
+	// times and the buffer grows and shrinks.
+	for round := 0; round < 100; round++ {
+		for q.Len() < 6 {
+			q.Enqueue(next)
+			next++
+		}
+		for q.Len() > 3 {
+			v, ok := q.Dequeue()
+			if !ok || v != want {
+				t.Fatalf("dequeue = %v,%v want %d,true", v, ok, want)
+			}
+			want++
+		}
+	}
+	got := q.ToSlice()
+	for i, v := range got {
+		if v != want+i {
+			t.Fatalf("ToSlice()[%d] = %d want %d", i, v, want+i)
+		}
+	}
+}
+
+func TestRemoveMiddleWrapped(t *testing.T) {
+	q := New[int](true)
+	// Advance head so that the contents wrap around the end of the buffer.
+	for i := 0; i < 6; i++ {
+		q.Enqueue(-1 - i)
+		q.Dequeue()
+	}
+	q.EnqueueMany(1, 2, 3, 4, 5, 6)
+	if !q.Remove(2) || !q.Remove(5) {
+		t.Fatal("expected removals to succeed")
+	}
+	if q.Remove(5) {
+		t.Fatal("expected second removal of 5 to fail")
+	}
+	got := q.ToSlice()
+	want := []int{1, 3, 4, 6}
+	if len(got) != len(want) {
+		t.Fatalf("ToSlice() = %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ToSlice() = %v want %v", got, want)
+		}
+	}
+	if !q.Enqueue(2) {
+		t.Fatal("expected re-enqueue of removed value to succeed")
+	}
+}
+
+func TestVacatedSlotsZeroed(t *testing.T) {
+	q := New[*int](false)
+	for i := 0; i < 5; i++ {
+		v := i
+		q.Enqueue(&v)
+	}
+	q.Dequeue()
+	q.Dequeue()
+	var mid *int
+	for i, n := 0, q.core.data.len(); i < n; i++ {
+		if *q.core.data.at(i).v == 3 {
+			mid = q.core.data.at(i).v
+		}
+	}
+	q.Remove(mid)
+	live := 0
+	for _, e := range q.core.data.buf {
+		if e.v != nil {
+			live++
+		}
+	}
+	if live != q.Len() {
+		t.Fatalf("non-nil slots = %d want %d (vacated slots must be zeroed)", live, q.Len())
+	}
+}
+
+func TestRingGrowShrink(t *testing.T) {
+	q := New[int](false)
+	for i := 0; i < 1000; i++ {
+		q.Enqueue(i)
+	}
+	grown := len(q.core.data.buf)
+	if grown < 1000 || grown&(grown-1) != 0 {
+		t.Fatalf("capacity = %d want power of two >= 1000", grown)
+	}
+	for i := 0; i < 990; i++ {
+		q.Dequeue()
+	}
+	if c := len(q.core.data.buf); c >= grown/4 {
+		t.Fatalf("capacity = %d after draining, want shrunk below %d", c, grown/4)
+	}
+	for i := 990; i < 1000; i++ {
+		if v, _ := q.Dequeue(); v != i {
+			t.Fatalf("dequeue = %d want %d", v, i)
+		}
+	}
+	q.EnqueueMany(1, 2, 3)
+	q.Clear()
+	if q.Len() != 0 || len(q.core.data.buf) != minRingSize {
+		t.Fatalf("after Clear len=%d cap=%d", q.Len(), len(q.core.data.buf))
+	}
+}
+
+func TestBoundedPolicies(t *testing.T) {
+	cases := []struct {
+		policy OverflowPolicy
+		want   []int
+		result Result
+	}{
+		{Reject, []int{1, 2, 3}, Full},
+		{DropOldest, []int{2, 3, 4}, Added},
+		{DropNewest, []int{1, 2, 4}, Added},
+	}
+	for _, c := range cases {
+		q := New[int](true, WithMaxSize(3, c.policy))
+		q.EnqueueMany(1, 2, 3)
+		if r := q.Offer(4); r != c.result {
+			t.Fatalf("%v: Offer(4) = %v want %v", c.policy, r, c.result)
+		}
+		got := q.ToSlice()
+		if len(got) != len(c.want) {
+			t.Fatalf("%v: ToSlice() = %v want %v", c.policy, got, c.want)
+		}
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Fatalf("%v: ToSlice() = %v want %v", c.policy, got, c.want)
+			}
+		}
+		// Evicted values must also leave the presence set.
+		for v := 1; v <= 4; v++ {
+			if q.Contains(v) != slicesContains(got, v) {
+				t.Fatalf("%v: Contains(%d) inconsistent with %v", c.policy, v, got)
+			}
+		}
+		if r := q.Offer(got[0]); r != Duplicate {
+			t.Fatalf("%v: Offer(dup) = %v want Duplicate", c.policy, r)
+		}
+	}
+}
+
+func TestOverflowFunc(t *testing.T) {
+	// Even values evict the oldest entry; odd values are rejected.
+	q := New[int](false, WithMaxSize(2, Reject))
+	q.SetOverflowFunc(func(v int) OverflowPolicy {
+		if v%2 == 0 {
+			return DropOldest
+		}
+		return Reject
+	})
+	added, rejected := q.OfferMany(1, 2, 3, 4, 5, 6)
+	if added != 4 {
+		t.Fatalf("added = %d want 4", added)
+	}
+	if len(rejected) != 2 || rejected[0] != 3 || rejected[1] != 5 {
+		t.Fatalf("rejected = %v want [3 5]", rejected)
+	}
+	got := q.ToSlice()
+	if len(got) != 2 || got[0] != 4 || got[1] != 6 {
+		t.Fatalf("ToSlice() = %v want [4 6]", got)
+	}
+	if q.MaxSize() != 2 {
+		t.Fatalf("MaxSize() = %d want 2", q.MaxSize())
+	}
+}
+
+func TestNextExpiry(t *testing.T) {
+	clk := &testClock{t: time.Unix(100, 0)}
+	q := New[int](false, WithClock(clk.now))
+	q.Enqueue(1)
+	if _, ok := q.NextExpiry(); ok {
+		t.Fatal("NextExpiry without a TTL must report none")
+	}
+	q.EnqueueWithTTL(2, 2*time.Second)
+	q.EnqueueWithTTL(3, time.Second)
+	if at, ok := q.NextExpiry(); !ok || !at.Equal(clk.t.Add(time.Second)) {
+		t.Fatalf("NextExpiry() = %v,%v want %v", at, ok, clk.t.Add(time.Second))
+	}
+	clk.advance(time.Second)
+	if at, ok := q.NextExpiry(); !ok || !at.Equal(clk.t.Add(time.Second)) {
+		t.Fatalf("NextExpiry() = %v,%v after the first expiry", at, ok)
+	}
+}
+
+// testClock is a manually advanced time source for TTL tests.
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestDefaultTTL(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	var expired []string
+	q := New[string](true, WithTTL(time.Second), WithClock(clk.now))
+	q.SetExpiryFunc(func(v string) { expired = append(expired, v) })
+	q.EnqueueMany("a", "b")
+	clk.advance(500 * time.Millisecond)
+	q.Enqueue("c")
+	if q.Len() != 3 {
+		t.Fatalf("len = %d want 3 before expiry", q.Len())
+	}
+	clk.advance(500 * time.Millisecond)
+	if v, ok := q.Peek(); !ok || v != "c" {
+		t.Fatalf("Peek() = %q,%v want c,true", v, ok)
+	}
+	if q.Len() != 1 || q.Contains("a") {
+		t.Fatalf("len = %d contains(a) = %v after expiry", q.Len(), q.Contains("a"))
+	}
+	if len(expired) != 2 || expired[0] != "a" || expired[1] != "b" {
+		t.Fatalf("expired = %v want [a b]", expired)
+	}
+	if !q.Enqueue("a") {
+		t.Fatal("expired value must leave the dedup set")
+	}
+	clk.advance(time.Second)
+	if v, ok := q.Dequeue(); ok {
+		t.Fatalf("Dequeue() = %q, want empty queue", v)
+	}
+	if len(expired) != 4 {
+		t.Fatalf("expired = %v want 4 values", expired)
+	}
+}
+
+func TestEnqueueWithTTL(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	var expired []int
+	q := New[int](false, WithClock(clk.now))
+	q.SetExpiryFunc(func(v int) { expired = append(expired, v) })
+	q.EnqueueWithTTL(1, 10*time.Second)
+	q.EnqueueWithTTL(2, time.Second)
+	q.Enqueue(3) // no default TTL: never expires
+	q.EnqueueWithTTL(4, 2*time.Second)
+	q.EnqueueWithTTL(5, 0)
+
+	clk.advance(time.Second)
+	if got := q.ToSlice(); len(got) != 4 || got[0] != 1 || got[1] != 3 || got[2] != 4 || got[3] != 5 {
+		t.Fatalf("ToSlice() = %v want [1 3 4 5]", got)
+	}
+	clk.advance(time.Second)
+	if q.Len() != 3 || q.Contains(4) {
+		t.Fatalf("len = %d contains(4) = %v want 3,false", q.Len(), q.Contains(4))
+	}
+	clk.advance(time.Hour)
+	if got := q.ToSlice(); len(got) != 2 || got[0] != 3 || got[1] != 5 {
+		t.Fatalf("ToSlice() = %v want [3 5]", got)
+	}
+	if len(expired) != 3 || expired[0] != 2 || expired[1] != 4 || expired[2] != 1 {
+		t.Fatalf("expired = %v want [2 4 1]", expired)
+	}
+}
+
+func TestTTLWithDefaultOverride(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	q := New[int](false, WithTTL(time.Second), WithClock(clk.now))
+	q.Enqueue(1)
+	q.EnqueueWithTTL(2, 0)
+	q.EnqueueWithTTL(3, time.Minute)
+	clk.advance(time.Second)
+	if got := q.ToSlice(); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("ToSlice() = %v want [2 3]", got)
+	}
+	clk.advance(time.Minute)
+	if v, ok := q.Dequeue(); !ok || v != 2 {
+		t.Fatalf("Dequeue() = %d,%v want 2,true", v, ok)
+	}
+	if !q.IsEmpty() {
+		t.Fatalf("queue should be empty, has %v", q.ToSlice())
+	}
+}
+
+func TestTTLFreesBoundedSpace(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	q := New[int](false, WithMaxSize(2, Reject), WithTTL(time.Second), WithClock(clk.now))
+	q.EnqueueMany(1, 2)
+	if r := q.Offer(3); r != Full {
+		t.Fatalf("Offer(3) = %v want Full", r)
+	}
+	clk.advance(time.Second)
+	if r := q.Offer(3); r != Added {
+		t.Fatalf("Offer(3) after expiry = %v want Added", r)
+	}
+	if got := q.ToSlice(); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("ToSlice() = %v want [3]", got)
+	}
+}
+
+func TestTTLIteratorSkipsExpired(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	q := New[int](false, WithClock(clk.now))
+	q.EnqueueWithTTL(1, time.Second)
+	q.Enqueue(2)
+	q.EnqueueWithTTL(3, time.Second)
+	clk.advance(time.Second)
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+	}
+	if len(got) != 1 || got[0] != 2 {
+		t.Fatalf("All() = %v want [2]", got)
+	}
+}
+
+func slicesContains(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDequeueMany(t *testing.T) {
+	q := New[int](true)
+	q.EnqueueMany(1, 2, 3, 4, 5)
+	got := q.DequeueMany(2)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("DequeueMany(2) = %v want [1 2]", got)
+	}
+	if !q.Enqueue(1) {
+		t.Fatal("expected dequeued value to leave the presence set")
+	}
+	buf := make([]int, 0, 8)
+	buf = q.DequeueManyInto(buf, 0)
+	if len(buf) != 4 || buf[0] != 3 || buf[3] != 1 {
+		t.Fatalf("DequeueManyInto(buf, 0) = %v want [3 4 5 1]", buf)
+	}
+	if got := q.DequeueMany(3); len(got) != 0 {
+		t.Fatalf("DequeueMany on empty = %v", got)
+	}
+	buf = q.DequeueManyInto(buf[:1], 3)
+	if len(buf) != 1 {
+		t.Fatalf("DequeueManyInto on empty changed dst: %v", buf)
+	}
+}
+
+func TestMoveToBack(t *testing.T) {
+	q := New[string](true, WithDuplicatePolicy(MoveToBack), WithMaxSize(3, Reject))
+	q.EnqueueMany("a", "b", "c")
+	if r := q.Offer("a"); r != Moved {
+		t.Fatalf("Offer(a) = %v want Moved", r)
+	}
+	if r := q.Offer("d"); r != Full {
+		t.Fatalf("Offer(d) = %v want Full", r)
+	}
+	if got := q.ToSlice(); len(got) != 3 || got[0] != "b" || got[1] != "c" || got[2] != "a" {
+		t.Fatalf("ToSlice() = %v want [b c a]", got)
+	}
+	// Touching the tail leaves the order unchanged.
+	if r := q.Offer("a"); r != Moved {
+		t.Fatalf("Offer(a) = %v want Moved", r)
+	}
+	if v, _ := q.Dequeue(); v != "b" {
+		t.Fatalf("Dequeue() = %q want b", v)
+	}
+}
+
+func TestMoveToBackChurn(t *testing.T) {
+	// Repeatedly touching values leaves tombstones behind; the queue must
+	// stay correct across the compactions that reclaim them.
+	const n = 100
+	q := New[int](true, WithDuplicatePolicy(MoveToBack))
+	for i := 0; i < n; i++ {
+		q.Enqueue(i)
+	}
+	for round := 0; round < 10; round++ {
+		for i := 0; i < n; i += 2 {
+			q.Enqueue(i)
+		}
+		if q.Len() != n {
+			t.Fatalf("round %d: len = %d want %d", round, q.Len(), n)
+		}
+		if q.core.dead > q.core.len() {
+			t.Fatalf("round %d: %d tombstones for %d values", round, q.core.dead, q.core.len())
+		}
+	}
+	got := q.DequeueMany(0)
+	for i, v := range got {
+		want := 2*i + 1 // odd values were never touched
+		if i >= n/2 {
+			want = 2 * (i - n/2)
+		}
+		if v != want {
+			t.Fatalf("got[%d] = %d want %d (%v)", i, v, want, got)
+		}
+	}
+	if !q.Enqueue(0) || q.Len() != 1 {
+		t.Fatal("queue should accept values after draining")
+	}
+}
+
+func TestIndexedContainsRemove(t *testing.T) {
+	q := New[int](false, WithIndex())
+	q.EnqueueMany(1, 2, 1, 3, 1)
+	if !q.Contains(1) || q.Contains(4) {
+		t.Fatal("Contains mismatch")
+	}
+	if !q.Remove(1) || !q.Remove(1) {
+		t.Fatal("expected two removals of 1")
+	}
+	if got := q.ToSlice(); len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 1 {
+		t.Fatalf("ToSlice() = %v want [2 3 1]", got)
+	}
+	if !q.Remove(1) || q.Contains(1) || q.Remove(1) {
+		t.Fatal("third 1 should be removable exactly once")
+	}
+	if len(q.core.multi) != 2 {
+		t.Fatalf("index has %d keys want 2", len(q.core.multi))
+	}
+}
+
+// checkIndex verifies that the occurrence lists of an indexed queue match
+// the ring exactly.
+func checkIndex(t *testing.T, q *Queue[int]) {
+	t.Helper()
+	want := map[int][]uint64{}
+	for p := q.core.data.head; p != q.core.data.tail; p++ {
+		if e := q.core.data.slot(p); e.exp != tombstone {
+			want[e.v] = append(want[e.v], p)
+		}
+	}
+	if len(want) != len(q.core.multi) {
+		t.Fatalf("index has %d keys, ring %d", len(q.core.multi), len(want))
+	}
+	for k, ps := range want {
+		o := q.core.multi[k]
+		if o == nil || o.len() != len(ps) {
+			t.Fatalf("key %d: index %v, ring positions %v", k, o, ps)
+		}
+		for i, p := range ps {
+			if o.pos[o.head+i] != p {
+				t.Fatalf("key %d: index %v, ring positions %v", k, o.pos[o.head:], ps)
+			}
+		}
+	}
+}
+
+func TestIndexedMatchesModel(t *testing.T) {
+	for _, policy := range []OverflowPolicy{DropOldest, DropNewest} {
+		rng := rand.New(rand.NewSource(int64(policy)))
+		q := New[int](false, WithIndex(), WithMaxSize(64, policy))
+		var model []int
+		for i := 0; i < 20000; i++ {
+			v := rng.Intn(16)
+			switch op := rng.Intn(10); {
+			case op < 5:
+				q.Enqueue(v)
+				if len(model) == 64 {
+					if policy == DropOldest {
+						model = model[1:]
+					} else {
+						model = model[:63]
+					}
+				}
+				model = append(model, v)
+			case op < 7:
+				got, ok := q.Dequeue()
+				if ok != (len(model) > 0) || (ok && got != model[0]) {
+					t.Fatalf("%v step %d: Dequeue() = %d,%v model %v", policy, i, got, ok, model)
+				}
+				if ok {
+					model = model[1:]
+				}
+			case op < 9:
+				want := slicesContains(model, v)
+				if q.Remove(v) != want {
+					t.Fatalf("%v step %d: Remove(%d) != %v", policy, i, v, want)
+				}
+				if want {
+					for j, x := range model {
+						if x == v {
+							model = append(model[:j:j], model[j+1:]...)
+							break
+						}
+					}
+				}
+			default:
+				if q.Contains(v) != slicesContains(model, v) {
+					t.Fatalf("%v step %d: Contains(%d) mismatch", policy, i, v)
+				}
+			}
+			if q.Len() != len(model) {
+				t.Fatalf("%v step %d: len = %d model %d", policy, i, q.Len(), len(model))
+			}
+			checkIndex(t, q)
+		}
+		got := q.ToSlice()
+		for i := range model {
+			if got[i] != model[i] {
+				t.Fatalf("%v: ToSlice() = %v model %v", policy, got, model)
+			}
+		}
+	}
+}
+
+func TestIndexedExpiry(t *testing.T) {
+	clk := &testClock{t: time.Unix(1000, 0)}
+	q := New[int](false, WithIndex(), WithClock(clk.now))
+	q.EnqueueWithTTL(1, 2*time.Second)
+	q.EnqueueWithTTL(2, time.Second)
+	q.Enqueue(1)
+	q.EnqueueWithTTL(2, time.Minute)
+	clk.advance(time.Second)
+	if q.Len() != 3 {
+		t.Fatalf("len = %d want 3", q.Len())
+	}
+	checkIndex(t, q)
+	clk.advance(time.Second)
+	if !q.Remove(1) || !q.Contains(2) || q.Contains(1) {
+		t.Fatalf("after expiry: %v", q.ToSlice())
+	}
+	checkIndex(t, q)
+}
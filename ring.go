@@ -0,0 +1,139 @@
+package xyqueue
+
+// minRingSize is the smallest backing array a ring allocates.
+const minRingSize = 8
+
+// ring is a growable circular buffer whose capacity is always a power of two.
+//
+// Elements are addressed by free-running positions: head is the position of
+// the first element and tail is one past the last, so the slot for position p
+// is buf[p&(len(buf)-1)] and the length is tail-head. Positions survive
+// wrap-around of the uint64 counters because the capacity divides 2^64.
+//
+// Vacated slots are always zeroed so the buffer never pins values (or memory
+// they point to) after they leave the queue. The buffer doubles when full and
+// halves when it falls to a quarter full, never going below min; steady churn
+// at a stable length therefore does no copying or allocation at all.
+type ring[E any] struct {
+	buf  []E
+	head uint64
+	tail uint64
+	min  int // minimum capacity, a power of two
+}
+
+// newRing returns a ring able to hold at least capacity elements without
+// growing.
+func newRing[E any](capacity int) ring[E] {
+	n := roundPow2(capacity)
+	return ring[E]{buf: make([]E, n), min: n}
+}
+
+// roundPow2 returns the smallest power of two >= n and >= minRingSize.
+func roundPow2(n int) int {
+	c := minRingSize
+	for c < n {
+		c <<= 1
+	}
+	return c
+}
+
+func (r *ring[E]) len() int { return int(r.tail - r.head) }
+
+func (r *ring[E]) mask() uint64 { return uint64(len(r.buf)) - 1 }
+
+// slot returns a pointer to the element at position p.
+func (r *ring[E]) slot(p uint64) *E { return &r.buf[p&r.mask()] }
+
+// at returns a pointer to the i-th element counted from the head.
+func (r *ring[E]) at(i int) *E { return r.slot(r.head + uint64(i)) }
+
+// pushBack appends v at the tail, growing the buffer when full.
+func (r *ring[E]) pushBack(v E) {
+	if r.len() == len(r.buf) {
+		r.resize(max(len(r.buf)<<1, minRingSize))
+	}
+	*r.slot(r.tail) = v
+	r.tail++
+}
+
+// pushFront prepends v at the head, growing the buffer when full.
+func (r *ring[E]) pushFront(v E) {
+	if r.len() == len(r.buf) {
+		r.resize(max(len(r.buf)<<1, minRingSize))
+	}
+	r.head--
+	*r.slot(r.head) = v
+}
+
+// popFront removes and returns the head element. The ring must not be empty.
+func (r *ring[E]) popFront() E {
+	var zero E
+	s := r.slot(r.head)
+	v := *s
+	*s = zero
+	r.head++
+	r.maybeShrink()
+	return v
+}
+
+// popBack removes and returns the tail element. The ring must not be empty.
+func (r *ring[E]) popBack() E {
+	var zero E
+	r.tail--
+	s := r.slot(r.tail)
+	v := *s
+	*s = zero
+	r.maybeShrink()
+	return v
+}
+
+// filter keeps the elements for which keep returns true, in order, and drops
+// the rest, closing the gaps in a single pass. keep may inspect or modify the
+// element it is given. Complexity: O(n).
+func (r *ring[E]) filter(keep func(e *E) bool) {
+	var zero E
+	w := r.head
+	for p := r.head; p != r.tail; p++ {
+		s := r.slot(p)
+		if !keep(s) {
+			continue
+		}
+		if w != p {
+			*r.slot(w) = *s
+		}
+		w++
+	}
+	for p := w; p != r.tail; p++ {
+		*r.slot(p) = zero
+	}
+	r.tail = w
+	r.maybeShrink()
+}
+
+// maybeShrink halves the buffer once it is at most a quarter full.
+func (r *ring[E]) maybeShrink() {
+	if len(r.buf) > r.min && r.len() <= len(r.buf)/4 {
+		r.resize(len(r.buf) >> 1)
+	}
+}
+
+// resize moves the elements into a new buffer of size n (a power of two no
+// smaller than the current length). Each element keeps its position.
+func (r *ring[E]) resize(n int) {
+	buf := make([]E, n)
+	m := uint64(n - 1)
+	for p := r.head; p != r.tail; p++ {
+		buf[p&m] = *r.slot(p)
+	}
+	r.buf = buf
+}
+
+// reset drops all elements and returns the buffer to its minimum size.
+func (r *ring[E]) reset() {
+	if len(r.buf) > r.min {
+		r.buf = make([]E, r.min)
+	} else {
+		clear(r.buf)
+	}
+	r.head, r.tail = 0, 0
+}